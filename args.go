@@ -0,0 +1,397 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krujos/scaleover-plugin/scaleover"
+)
+
+//usageText is printed under any problem with the arguments
+const usageText = "Usage: cf scaleover\n" +
+	"\tcf scaleover APP1[,APP...] APP2[:WEIGHT][,APP[:WEIGHT]...] ROLLOVER_DURATION [OPTIONS]\n" +
+	"\tcf scaleover --from APP[,APP...] --to APP[:WEIGHT][,APP[:WEIGHT]...] ROLLOVER_DURATION [OPTIONS]\n" +
+	"\tcf scaleover --resume [--state-file PATH]\n" +
+	"Options may come before, between or after the apps and duration, as --flag value or --flag=value. See `cf help scaleover`."
+
+//invocation is what cf scaleover was asked to do
+type invocation struct {
+	resume   bool
+	sources  []string
+	targets  []string
+	weights  []int
+	duration time.Duration
+	opts     scaleover.Options
+}
+
+//stringList collects a flag that can be given more than once. Once settled,
+//the next value starts the list again, so the command line replaces the rollout file's list.
+type stringList struct {
+	values  []string
+	settled bool
+}
+
+func (l *stringList) String() string { return strings.Join(l.values, ",") }
+
+func (l *stringList) Set(value string) error {
+	if l.settled {
+		l.values, l.settled = nil, false
+	}
+	l.values = append(l.values, value)
+	return nil
+}
+
+//countFlag is an instance count that can also be given as a percentage of APP1's instances,
+//like 10%. Setting one clears the other.
+type countFlag struct {
+	count   *int
+	percent *int
+}
+
+func (f countFlag) String() string {
+	if f.percent != nil && *f.percent > 0 {
+		return fmt.Sprintf("%d%%", *f.percent)
+	}
+	if f.count != nil {
+		return strconv.Itoa(*f.count)
+	}
+	return ""
+}
+
+func (f countFlag) Set(value string) error {
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || percent < 1 || percent > 100 {
+			return errors.New("percentage out of range")
+		}
+		*f.percent = percent
+		return nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*f.count, *f.percent = count, 0
+	return nil
+}
+
+//parseArgs reads the apps, the duration and every option, in whatever order they come
+func (cmd *ScaleoverCmd) parseArgs(args []string) (invocation, error) {
+	inv := invocation{opts: scaleover.DefaultOptions()}
+	opts := &inv.opts
+
+	var from, to, steps, duration, config, onStartFailure, minAvailable, maxSurge string
+	var fileFrom, fileTo string
+	var noRouteCheck, noQuotaCheck bool
+	var requireRoutes stringList
+	var seen = map[string]bool{}
+
+	flags := flag.NewFlagSet("scaleover", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.StringVar(&from, "from", "", "")
+	flags.StringVar(&to, "to", "", "")
+	flags.StringVar(&config, "config", "", "")
+	flags.BoolVar(&inv.resume, "resume", false, "")
+	flags.BoolVar(&noRouteCheck, "no-route-check", false, "")
+	flags.Var(&requireRoutes, "require-route", "")
+	flags.BoolVar(&noQuotaCheck, "no-quota-check", false, "")
+	flags.BoolVar(&opts.WaitForStarted, "wait-for-start", false, "")
+	flags.DurationVar(&opts.PostStartSleep, "post-start-sleep", 0, "")
+	flags.DurationVar(&opts.StartTimeout, "start-timeout", opts.StartTimeout, "")
+	flags.StringVar(&onStartFailure, "on-start-failure", "", "")
+	flags.StringVar(&opts.Balance, "balance", opts.Balance, "")
+	flags.IntVar(&opts.TargetInstances, "target-instances", 0, "")
+	flags.StringVar(&opts.CapacityMatch, "capacity-match", "", "")
+	flags.StringVar(&minAvailable, "min-available", "", "")
+	flags.StringVar(&maxSurge, "max-surge", "", "")
+	flags.Var(countFlag{&opts.Leave, &opts.LeavePercent}, "leave", "")
+	flags.Var(countFlag{&opts.BatchSize, &opts.BatchSizePercent}, "batch-size", "")
+	flags.BoolVar(&opts.RollbackOnFailure, "rollback-on-failure", false, "")
+	flags.StringVar(&opts.StateFile, "state-file", opts.StateFile, "")
+	flags.StringVar(&opts.OnInterrupt, "on-interrupt", opts.OnInterrupt, "")
+	flags.StringVar(&opts.HealthCheck.URL, "health-url", "", "")
+	flags.IntVar(&opts.HealthCheck.Status, "health-status", opts.HealthCheck.Status, "")
+	flags.StringVar(&opts.HealthCheck.Body, "health-body", "", "")
+	flags.StringVar(&opts.HealthCheck.BodyRegex, "health-body-regex", "", "")
+	flags.IntVar(&opts.HealthCheck.Successes, "health-successes", opts.HealthCheck.Successes, "")
+	flags.DurationVar(&opts.HealthCheck.Timeout, "health-timeout", opts.HealthCheck.Timeout, "")
+	flags.StringVar(&opts.MapRoute, "map-route", "", "")
+	flags.BoolVar(&opts.UnmapOld, "unmap-old", false, "")
+	flags.IntVar(&opts.Retry.Retries, "retries", opts.Retry.Retries, "")
+	flags.DurationVar(&opts.Retry.Backoff, "retry-backoff", opts.Retry.Backoff, "")
+	flags.StringVar(&opts.Strategy, "strategy", opts.Strategy, "")
+	flags.StringVar(&steps, "steps", "", "")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "")
+	flags.StringVar(&opts.Output, "output", opts.Output, "")
+	flags.StringVar(&opts.LogFile, "log-file", "", "")
+
+	//the rollout file's values go in first, as though they came before everything on the command line
+	if path := configPath(args[1:]); path != "" {
+		file, err := loadConfig(path)
+		if err != nil {
+			return inv, err
+		}
+		if err = file.apply(flags); err != nil {
+			return inv, err
+		}
+		duration, opts.Hooks = file.duration, file.hooks
+		requireRoutes.settled = true
+		//kept apart so --from and --to on the command line can be told from the file's
+		fileFrom, fileTo, from, to = from, to, "", ""
+	}
+
+	//the flag package stops at the first positional argument, so pick them off one at a time
+	var positional []string
+	for rest := args[1:]; ; {
+		if err := flags.Parse(rest); err != nil {
+			return inv, usageError("%s", flagError(err))
+		}
+		if rest = flags.Args(); len(rest) == 0 {
+			break
+		}
+		positional, rest = append(positional, rest[0]), rest[1:]
+	}
+	flags.Visit(func(f *flag.Flag) { seen[f.Name] = true })
+
+	opts.EnforceRoutes = !noRouteCheck
+	opts.QuotaCheck = !noQuotaCheck
+	opts.RequireRoutes = requireRoutes.values
+	//anything that depends on knowing which new instances are running waits for them
+	if opts.RollbackOnFailure || seen["start-timeout"] || seen["on-start-failure"] || seen["min-available"] ||
+		opts.Balance == scaleover.BalanceReady {
+		opts.WaitForStarted = true
+	}
+	limits := []struct {
+		name   string
+		value  string
+		amount **scaleover.Amount
+	}{
+		{"min-available", minAvailable, &opts.MinAvailable},
+		{"max-surge", maxSurge, &opts.MaxSurge},
+	}
+	for _, limit := range limits {
+		if seen[limit.name] {
+			amount, err := scaleover.ParseAmount(limit.value)
+			if err != nil {
+				return inv, usageError("--%s %s", limit.name, err)
+			}
+			*limit.amount = &amount
+		}
+	}
+	if seen["on-start-failure"] {
+		var err error
+		if opts.OnStartFailure, err = scaleover.ParseStartFailurePolicy(onStartFailure, opts.OnStartFailure); err != nil {
+			return inv, usageError("%s", err)
+		}
+	}
+	if seen["steps"] {
+		if seen["strategy"] && opts.Strategy != scaleover.StrategySteps {
+			return inv, usageError("--steps can't be used with --strategy %s", opts.Strategy)
+		}
+		var err error
+		if opts.Steps, err = scaleover.ParseSteps(steps); err != nil {
+			return inv, usageError("%s", err)
+		}
+		opts.Strategy = scaleover.StrategySteps
+	}
+
+	//a resumed scaleover keeps the options it was started with, so anything else would be ignored
+	if inv.resume {
+		switch {
+		case seen["config"]:
+			return inv, usageError("--resume can't be used with --config")
+		case len(positional) > 0 || seen["from"] || seen["to"]:
+			return inv, usageError("--resume carries on with the apps and duration it was started with")
+		}
+		var other string
+		flags.Visit(func(f *flag.Flag) {
+			if other == "" && f.Name != "resume" && f.Name != "state-file" {
+				other = f.Name
+			}
+		})
+		if other != "" {
+			return inv, usageError("--resume carries on with the options it was started with, --%s can't be changed", other)
+		}
+		return inv, checkOptions(inv.opts, seen)
+	}
+
+	//APP1 APP2 DURATION take the place of --from, --to and the file's duration, and DURATION alone its duration
+	switch len(positional) {
+	case 0, 1:
+		if from == "" {
+			from = fileFrom
+		}
+		if to == "" {
+			to = fileTo
+		}
+		if (from == "") != (to == "") {
+			return inv, usageError("--from and --to go together")
+		}
+		if len(positional) == 1 {
+			duration = positional[0]
+		}
+		if from == "" || duration == "" {
+			return inv, usageError("Name the source apps, the target apps and how long the scaleover should take")
+		}
+		positional = []string{from, to, duration}
+	case 2:
+		return inv, usageError("Name the source apps, the target apps and how long the scaleover should take")
+	case 3:
+		if from != "" || to != "" {
+			return inv, usageError("Name the apps either with --from and --to or as arguments, not both")
+		}
+	default:
+		return inv, usageError("Unexpected argument %s", positional[3])
+	}
+
+	var err error
+	inv.sources = parseApps(positional[0])
+	if inv.targets, inv.weights, err = parseWeightedApps(positional[1]); err != nil {
+		return inv, usageError("%s", err)
+	}
+	if err = checkApps(inv.sources, inv.targets); err != nil {
+		return inv, usageError("%s", err)
+	}
+	if inv.duration, err = cmd.parseTime(positional[2]); err != nil {
+		return inv, usageError("%s", err)
+	}
+	return inv, checkOptions(inv.opts, seen)
+}
+
+//checkOptions rejects values that are out of range or don't make sense together
+func checkOptions(opts scaleover.Options, seen map[string]bool) error {
+	switch {
+	case opts.Leave < 0:
+		return usageError("--leave must be 0 or more")
+	case opts.BatchSize < 1:
+		return usageError("--batch-size must be at least 1")
+	case opts.Retry.Retries < 0:
+		return usageError("--retries must be 0 or more")
+	case opts.HealthCheck.Status < 100 || opts.HealthCheck.Status > 599:
+		return usageError("--health-status must be an HTTP status code")
+	case opts.HealthCheck.Successes < 1:
+		return usageError("--health-successes must be at least 1")
+	case opts.StartTimeout <= 0:
+		return usageError("--start-timeout must be longer than 0s")
+	case opts.PostStartSleep < 0 || opts.HealthCheck.Timeout < 0 || opts.Retry.Backoff < 0:
+		return usageError("Durations must be positive, in the format of 1m")
+	case opts.StateFile == "":
+		return usageError("--state-file needs a path")
+	case !scaleover.ValidStrategy(opts.Strategy):
+		return usageError("--strategy must be linear, exponential, canary or steps, not %s", opts.Strategy)
+	case !scaleover.ValidBalance(opts.Balance):
+		return usageError("--balance must be plan or ready, not %s", opts.Balance)
+	case seen["target-instances"] && opts.TargetInstances < 1:
+		return usageError("--target-instances must be at least 1")
+	case !scaleover.ValidCapacityMatch(opts.CapacityMatch):
+		return usageError("--capacity-match must be memory, not %s", opts.CapacityMatch)
+	case opts.TargetInstances > 0 && opts.CapacityMatch != "":
+		return usageError("--target-instances and --capacity-match don't go together")
+	case !scaleover.ValidOutput(opts.Output):
+		return usageError("--output must be text or json, not %s", opts.Output)
+	case !scaleover.ValidInterruptPolicy(opts.OnInterrupt):
+		return usageError("--on-interrupt must be hold or rollback, not %s", opts.OnInterrupt)
+	case opts.UnmapOld && opts.MapRoute == "":
+		return usageError("--unmap-old needs --map-route")
+	case seen["post-start-sleep"] && !opts.WaitForStarted:
+		return usageError("--post-start-sleep needs --wait-for-start, --start-timeout, --on-start-failure or --rollback-on-failure")
+	case seen["health-url"] && opts.HealthCheck.URL == "":
+		return usageError("--health-url needs a URL")
+	}
+	for _, r := range opts.RequireRoutes {
+		if r == "" {
+			return usageError("--require-route needs a route")
+		}
+	}
+	if _, err := regexp.Compile(opts.HealthCheck.BodyRegex); err != nil {
+		return usageError("--health-body-regex doesn't compile: %s", err)
+	}
+	return nil
+}
+
+//usageError is a specific complaint about the arguments, followed by the usage
+func usageError(format string, a ...interface{}) error {
+	return fmt.Errorf(format+"\n\n%s", append(a, usageText)...)
+}
+
+//flagError words the flag package's errors the way cf users write flags
+func flagError(err error) string {
+	message := err.Error()
+	var name string
+	switch {
+	case strings.HasPrefix(message, "flag provided but not defined: "):
+		name = strings.TrimLeft(strings.TrimPrefix(message, "flag provided but not defined: "), "-")
+		return "Unknown option --" + name
+	case strings.HasPrefix(message, "flag needs an argument: "):
+		name = strings.TrimLeft(strings.TrimPrefix(message, "flag needs an argument: "), "-")
+		return "--" + name + " needs a value"
+	}
+	if match := regexp.MustCompile(`^invalid (?:boolean )?value ("[^"]*") for (?:flag )?-+([^:]+)`).FindStringSubmatch(message); match != nil {
+		return fmt.Sprintf("Invalid value %s for --%s", match[1], match[2])
+	}
+	return message
+}
+
+//parseApps reads APP[,APP...]
+func parseApps(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+//parseWeightedApps reads APP[:WEIGHT][,APP[:WEIGHT]...], weights default to 1
+func parseWeightedApps(list string) ([]string, []int, error) {
+	names := parseApps(list)
+	weights := make([]int, len(names))
+	for i, name := range names {
+		weights[i] = 1
+		if colon := strings.LastIndex(name, ":"); colon >= 0 {
+			weight, err := strconv.Atoi(name[colon+1:])
+			if err != nil || weight < 1 {
+				return nil, nil, fmt.Errorf("Weight for %s must be a whole number of at least 1", name[:colon])
+			}
+			names[i], weights[i] = name[:colon], weight
+		}
+	}
+	return names, weights, nil
+}
+
+//checkApps makes sure there's at least one source and one target, and no app is named twice
+func checkApps(sources []string, targets []string) error {
+	if len(sources) == 0 || len(targets) == 0 {
+		return errors.New("Name at least one source and one target app")
+	}
+
+	seen := map[string]bool{}
+	for _, name := range append(append([]string{}, sources...), targets...) {
+		if seen[name] {
+			return fmt.Errorf("%s is named more than once, each app can only be a source or a target", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/krujos/scaleover-plugin/scaleover"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Several sources and targets", func() {
+	var scaleoverCmdPlugin *ScaleoverCmd
+
+	BeforeEach(func() {
+		scaleoverCmdPlugin = &ScaleoverCmd{}
+	})
+
+	It("reads --from and --to in place of the first two arguments", func() {
+		inv, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--from", "blue,red", "--to", "green", "1m", "--leave", "1"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(inv.sources).Should(Equal([]string{"blue", "red"}))
+		Ω(inv.targets).Should(Equal([]string{"green"}))
+		Ω(inv.duration).Should(Equal(time.Minute))
+		Ω(inv.opts.Leave).Should(Equal(1))
+
+		_, err = scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--from", "blue", "1m"})
+		Ω(err.Error()).Should(HavePrefix("--from and --to go together"))
+	})
+
+	It("won't take apps both as flags and as arguments", func() {
+		_, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--from", "blue", "--to", "green", "red", "yellow", "1m"})
+		Ω(err.Error()).Should(HavePrefix("Name the apps either with --from and --to or as arguments, not both"))
+	})
+
+	It("reads target weights", func() {
+		names, weights, err := parseWeightedApps("green:3, canary")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(names).Should(Equal([]string{"green", "canary"}))
+		Ω(weights).Should(Equal([]int{3, 1}))
+
+		_, _, err = parseWeightedApps("green:0")
+		Ω(err).Should(MatchError("Weight for green must be a whole number of at least 1"))
+	})
+
+	It("accepts lists of apps but not the same app twice", func() {
+		Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "blue,red", "green:3,canary:1", "1m"}))).Should(BeNil())
+		Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "blue,red", "red", "1m"}))).ShouldNot(BeNil())
+		Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "blue", "green:x", "1m"}))).ShouldNot(BeNil())
+	})
+	It("names the app that was given twice", func() {
+		_, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "blue,red", "red", "1m"})
+		Ω(err.Error()).Should(HavePrefix("red is named more than once"))
+	})
+})
+
+//parseError keeps just the error from parseArgs
+func parseError(_ invocation, err error) error {
+	return err
+}
+
+//parsedOptions keeps just the options from parseArgs
+func parsedOptions(inv invocation, _ error) scaleover.Options {
+	return inv.opts
+}
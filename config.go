@@ -0,0 +1,199 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/krujos/scaleover-plugin/scaleover"
+	"gopkg.in/yaml.v2"
+)
+
+//listKeys are the rollout file keys that can hold a list as well as a single value
+var listKeys = map[string]bool{"from": true, "to": true, "steps": true, "require-route": true}
+
+//rolloutConfig is a --config file. Its keys are the option names without the dashes,
+//plus duration and a hooks section, e.g.
+//
+//	from: blue
+//	to: [green:3, canary:1]
+//	duration: 10m
+//	batch-size: 2
+//	require-route: [www.example.com]
+//	hooks:
+//	  after: ./smoke-test.sh
+type rolloutConfig struct {
+	path     string
+	values   map[string]interface{}
+	duration string
+	hooks    scaleover.Hooks
+}
+
+//configPath finds --config among the arguments, before the rest are parsed
+func configPath(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "--config" || arg == "-config":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+		case strings.HasPrefix(arg, "--config="):
+			return strings.TrimPrefix(arg, "--config=")
+		case strings.HasPrefix(arg, "-config="):
+			return strings.TrimPrefix(arg, "-config=")
+		}
+	}
+	return ""
+}
+
+//loadConfig reads a rollout file, as JSON when it's named .json and YAML otherwise
+func loadConfig(path string) (*rolloutConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read the rollout file: %s", err)
+	}
+
+	config := &rolloutConfig{path: path, values: map[string]interface{}{}}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &config.values)
+	} else {
+		err = yaml.Unmarshal(data, &config.values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s isn't valid: %s", path, err)
+	}
+
+	if value, ok := config.values["duration"]; ok {
+		if config.duration, err = config.scalar("duration", value); err != nil {
+			return nil, err
+		}
+		delete(config.values, "duration")
+	}
+	if value, ok := config.values["hooks"]; ok {
+		if config.hooks, err = config.parseHooks(value); err != nil {
+			return nil, err
+		}
+		delete(config.values, "hooks")
+	}
+	return config, nil
+}
+
+//apply sets every option in the file on the flags, so the command line can override them.
+//Keys that aren't options, or values of the wrong type, don't match the schema and are rejected.
+func (config *rolloutConfig) apply(flags *flag.FlagSet) error {
+	keys := make([]string, 0, len(config.values))
+	for key := range config.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if flags.Lookup(key) == nil || key == "config" || key == "resume" {
+			return fmt.Errorf("%s: unknown key %s", config.path, key)
+		}
+
+		var values []string
+		if list, ok := config.values[key].([]interface{}); ok && listKeys[key] {
+			for _, item := range list {
+				value, err := config.scalar(key, item)
+				if err != nil {
+					return err
+				}
+				values = append(values, value)
+			}
+			if key != "require-route" {
+				values = []string{strings.Join(values, ",")}
+			}
+		} else {
+			value, err := config.scalar(key, config.values[key])
+			if err != nil {
+				return err
+			}
+			values = []string{value}
+		}
+
+		for _, value := range values {
+			if err := flags.Set(key, value); err != nil {
+				return fmt.Errorf("%s: invalid value %q for %s", config.path, value, key)
+			}
+		}
+	}
+	return nil
+}
+
+//scalar is a single string, number or boolean value as it would be written on the command line
+func (config *rolloutConfig) scalar(key string, value interface{}) (string, error) {
+	switch value.(type) {
+	case string, int, int64, float64, bool:
+		return fmt.Sprint(value), nil
+	case nil:
+		return "", fmt.Errorf("%s: %s needs a value", config.path, key)
+	}
+	if listKeys[key] {
+		return "", fmt.Errorf("%s: %s must be a value or a list of values", config.path, key)
+	}
+	return "", fmt.Errorf("%s: %s must be a single value", config.path, key)
+}
+
+//parseHooks reads the hooks section, each stage being one command or a list of them
+func (config *rolloutConfig) parseHooks(section interface{}) (scaleover.Hooks, error) {
+	var hooks scaleover.Hooks
+	stages := map[string]interface{}{}
+	switch section := section.(type) {
+	case map[interface{}]interface{}:
+		for stage, commands := range section {
+			stages[fmt.Sprint(stage)] = commands
+		}
+	case map[string]interface{}:
+		stages = section
+	default:
+		return hooks, fmt.Errorf("%s: hooks must map before, after or on-failure to commands", config.path)
+	}
+
+	for stage, value := range stages {
+		var commands []string
+		if list, ok := value.([]interface{}); ok {
+			for _, item := range list {
+				command, isString := item.(string)
+				if !isString || command == "" {
+					return hooks, fmt.Errorf("%s: the %s hooks must be commands", config.path, stage)
+				}
+				commands = append(commands, command)
+			}
+		} else if command, ok := value.(string); ok && command != "" {
+			commands = []string{command}
+		} else {
+			return hooks, fmt.Errorf("%s: the %s hooks must be commands", config.path, stage)
+		}
+
+		switch stage {
+		case "before":
+			hooks.Before = commands
+		case "after":
+			hooks.After = commands
+		case "on-failure":
+			hooks.OnFailure = commands
+		default:
+			return hooks, fmt.Errorf("%s: unknown hook %s, hooks can run before, after or on-failure", config.path, stage)
+		}
+	}
+	return hooks, nil
+}
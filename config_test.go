@@ -0,0 +1,152 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/krujos/scaleover-plugin/scaleover"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rollout files", func() {
+	var scaleoverCmdPlugin *ScaleoverCmd
+	var dir string
+
+	BeforeEach(func() {
+		scaleoverCmdPlugin = &ScaleoverCmd{}
+		var err error
+		dir, err = ioutil.TempDir("", "scaleover-config")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	write := func(name string, contents string) string {
+		path := filepath.Join(dir, name)
+		Ω(ioutil.WriteFile(path, []byte(contents), 0644)).Should(Succeed())
+		return path
+	}
+
+	It("reads the apps, duration and options from YAML", func() {
+		path := write("rollout.yml", `
+from: blue
+to: [green:3, canary]
+duration: 10m
+strategy: exponential
+batch-size: 2
+leave: 1
+wait-for-start: true
+health-url: https://green.example.com/health
+health-successes: 3
+require-route: [www.example.com, api.example.com/v2]
+hooks:
+  before: ./announce.sh
+  after: [./smoke-test.sh, ./announce.sh done]
+  on-failure: ./page.sh
+`)
+		inv, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config", path})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(inv.sources).Should(Equal([]string{"blue"}))
+		Ω(inv.targets).Should(Equal([]string{"green", "canary"}))
+		Ω(inv.weights).Should(Equal([]int{3, 1}))
+		Ω(inv.duration).Should(Equal(10 * time.Minute))
+		Ω(inv.opts.Strategy).Should(Equal(scaleover.StrategyExponential))
+		Ω(inv.opts.BatchSize).Should(Equal(2))
+		Ω(inv.opts.Leave).Should(Equal(1))
+		Ω(inv.opts.WaitForStarted).Should(BeTrue())
+		Ω(inv.opts.HealthCheck.URL).Should(Equal("https://green.example.com/health"))
+		Ω(inv.opts.HealthCheck.Successes).Should(Equal(3))
+		Ω(inv.opts.RequireRoutes).Should(Equal([]string{"www.example.com", "api.example.com/v2"}))
+		Ω(inv.opts.Hooks).Should(Equal(scaleover.Hooks{
+			Before:    []string{"./announce.sh"},
+			After:     []string{"./smoke-test.sh", "./announce.sh done"},
+			OnFailure: []string{"./page.sh"},
+		}))
+	})
+
+	It("reads JSON", func() {
+		path := write("rollout.json", `{
+	"from": ["blue", "red"],
+	"to": "green",
+	"duration": "1m",
+	"batch-size": 4,
+	"steps": ["10%", "50%", "100%"]
+}`)
+		inv, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config=" + path})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(inv.sources).Should(Equal([]string{"blue", "red"}))
+		Ω(inv.opts.BatchSize).Should(Equal(4))
+		Ω(inv.opts.Strategy).Should(Equal(scaleover.StrategySteps))
+		Ω(inv.opts.Steps).Should(Equal([]int{10, 50, 100}))
+	})
+
+	It("lets the command line override the file", func() {
+		path := write("rollout.yml", "from: blue\nto: green\nduration: 10m\nbatch-size: 2\nrequire-route: www.example.com\n")
+		inv, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config", path, "--batch-size=5",
+			"--require-route", "api.example.com", "5m"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(inv.duration).Should(Equal(5 * time.Minute))
+		Ω(inv.opts.BatchSize).Should(Equal(5))
+		Ω(inv.opts.RequireRoutes).Should(Equal([]string{"api.example.com"}))
+
+		inv, err = scaleoverCmdPlugin.parseArgs([]string{"scaleover", "red", "yellow", "1m", "--config", path})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(inv.sources).Should(Equal([]string{"red"}))
+		Ω(inv.targets).Should(Equal([]string{"yellow"}))
+		Ω(inv.opts.BatchSize).Should(Equal(2))
+	})
+
+	It("rejects keys that aren't in the schema", func() {
+		path := write("rollout.yml", "from: blue\nto: green\nduration: 1m\nbatchsize: 2\n")
+		_, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config", path})
+		Ω(err).Should(MatchError(path + ": unknown key batchsize"))
+
+		path = write("hooks.yml", "from: blue\nto: green\nduration: 1m\nhooks:\n  during: ./x.sh\n")
+		_, err = scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config", path})
+		Ω(err).Should(MatchError(path + ": unknown hook during, hooks can run before, after or on-failure"))
+	})
+
+	It("rejects values of the wrong type", func() {
+		path := write("rollout.yml", "from: blue\nto: green\nduration: 1m\nleave: lots\n")
+		_, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config", path})
+		Ω(err).Should(MatchError(path + `: invalid value "lots" for leave`))
+
+		path = write("list.yml", "from: blue\nto: green\nduration: 1m\nbatch-size: [1, 2]\n")
+		_, err = scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config", path})
+		Ω(err).Should(MatchError(path + ": batch-size must be a single value"))
+	})
+
+	It("checks the file's options like the command line's", func() {
+		path := write("rollout.yml", "from: blue\nto: green\nduration: 1m\nbatch-size: 0\n")
+		_, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config", path})
+		Ω(err.Error()).Should(HavePrefix("--batch-size must be at least 1"))
+	})
+
+	It("says when the file can't be read or parsed", func() {
+		_, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config", filepath.Join(dir, "missing.yml")})
+		Ω(err.Error()).Should(HavePrefix("Can't read the rollout file"))
+
+		path := write("rollout.yml", "from: [blue\n")
+		_, err = scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--config", path})
+		Ω(err.Error()).Should(HavePrefix(path + " isn't valid"))
+	})
+})
@@ -0,0 +1,267 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+//exitCode is what the end to end specs swap exit for, to stop the plugin where it would have ended
+type exitCode int
+
+var _ = Describe("Scaleover against a simulated Cloud Foundry", func() {
+	var cf *fakeCF
+	var dev *fakeSpace
+	var blue, green *fakeApp
+	var stateFile string
+	var tmpDir string
+	shared := fakeRoute{host: "www", domain: "example.com"}
+
+	scaleover := func(args ...string) (code int) {
+		defer func() {
+			if r := recover(); r != nil {
+				exited, ok := r.(exitCode)
+				if !ok {
+					panic(r)
+				}
+				code = int(exited)
+			}
+		}()
+		args = append([]string{"scaleover"}, args...)
+		(&ScaleoverCmd{clock: cf}).ScaleoverCommand(cf, append(args, "--state-file", stateFile))
+		return 0
+	}
+
+	BeforeEach(func() {
+		exit = func(code int) { panic(exitCode(code)) }
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "scaleover-e2e")
+		Ω(err).ShouldNot(HaveOccurred())
+		stateFile = filepath.Join(tmpDir, "state.json")
+
+		cf = newFakeCF()
+		dev = cf.addSpace("acme", "dev")
+		blue = cf.addApp(dev, "blue", 4, "started", 256)
+		green = cf.addApp(dev, "green", 1, "stopped", 256)
+		cf.addRoute(blue, shared)
+		cf.addRoute(green, shared)
+	})
+
+	AfterEach(func() {
+		exit = os.Exit
+		cf.close()
+		os.RemoveAll(tmpDir)
+	})
+
+	It("rolls every instance over to the target", func() {
+		Ω(scaleover("blue", "green", "0s")).Should(Equal(0))
+
+		Ω(green.state).Should(Equal("started"))
+		Ω(green.count).Should(Equal(4))
+		Ω(cf.running(green)).Should(Equal(4))
+		Ω(blue.state).Should(Equal("stopped"))
+		Ω(stateFile).ShouldNot(BeAnExistingFile())
+	})
+
+	It("keeps the capacity up while new instances start when waiting for them", func() {
+		cf.StartLatency = 30 * time.Second
+
+		Ω(scaleover("blue", "green", "0s", "--wait-for-start", "--batch-size", "2")).Should(Equal(0))
+
+		Ω(cf.running(green)).Should(Equal(4))
+		Ω(cf.elapsed()).Should(BeNumerically(">=", time.Minute))
+		for _, running := range cf.capacity {
+			Ω(running).Should(BeNumerically(">=", 4))
+		}
+	})
+
+	It("rolls both apps back when the new instances crash", func() {
+		cf.CrashProbability = 1
+
+		Ω(scaleover("blue", "green", "0s", "--rollback-on-failure")).Should(Equal(1))
+
+		Ω(blue.state).Should(Equal("started"))
+		Ω(blue.count).Should(Equal(4))
+		Ω(cf.running(blue)).Should(Equal(4))
+		Ω(green.state).Should(Equal("stopped"))
+		Ω(green.count).Should(Equal(1))
+		Ω(stateFile).ShouldNot(BeAnExistingFile())
+	})
+
+	It("scales a stopped target back to its instance count when rolling back", func() {
+		cf.CrashProbability = 1
+
+		Ω(scaleover("blue", "green", "0s", "--rollback-on-failure", "--batch-size", "2")).Should(Equal(1))
+		Ω(blue.count).Should(Equal(4))
+		Ω(green.state).Should(Equal("stopped"))
+		Ω(green.count).Should(Equal(1))
+	})
+
+	It("keeps the state file when a scaleover fails without rolling back", func() {
+		cf.CrashProbability = 1
+
+		Ω(scaleover("blue", "green", "0s", "--wait-for-start")).Should(Equal(1))
+		Ω(stateFile).Should(BeAnExistingFile())
+	})
+
+	It("times out new instances that are slow to start and acts on the timeout", func() {
+		cf.StartLatency = 10 * time.Minute
+
+		Ω(scaleover("blue", "green", "0s", "--start-timeout", "1m", "--on-start-failure", "timeout=rollback")).Should(Equal(1))
+		Ω(cf.elapsed()).Should(BeNumerically("<", 2*time.Minute))
+		Ω(blue.count).Should(Equal(4))
+		Ω(green.state).Should(Equal("stopped"))
+	})
+
+	It("carries on past crashes when told to continue", func() {
+		cf.CrashProbability = 1
+
+		Ω(scaleover("blue", "green", "0s", "--on-start-failure", "crash=continue")).Should(Equal(0))
+		Ω(blue.state).Should(Equal("stopped"))
+		Ω(green.count).Should(Equal(4))
+	})
+
+	It("never drops below the starting capacity when balancing by readiness", func() {
+		cf.CrashProbability = 0.5
+		cf.StartLatency = 10 * time.Second
+
+		Ω(scaleover("blue", "green", "0s", "--balance", "ready")).Should(Equal(0))
+		for _, running := range cf.capacity {
+			Ω(running).Should(BeNumerically(">=", 4))
+		}
+		Ω(cf.running(blue) + cf.running(green)).Should(BeNumerically(">=", 4))
+	})
+
+	It("moves a route from the source to the target with --map-route and --unmap-old", func() {
+		next := fakeRoute{host: "next", domain: "example.com"}
+		cf.addRoute(blue, next)
+
+		Ω(scaleover("blue", "green", "0s", "--batch-size", "2", "--map-route", "next.example.com", "--unmap-old")).Should(Equal(0))
+		Ω(green.hasRoute(next)).Should(BeTrue())
+		Ω(blue.hasRoute(next)).Should(BeFalse())
+		Ω(blue.hasRoute(shared)).Should(BeTrue())
+	})
+
+	It("keeps the route on the source while --balance ready keeps its instances", func() {
+		next := fakeRoute{host: "next", domain: "example.com"}
+		cf.addRoute(blue, next)
+		cf.CrashProbability = 1
+
+		Ω(scaleover("blue", "green", "0s", "--balance", "ready", "--map-route", "next.example.com", "--unmap-old")).Should(Equal(0))
+		Ω(blue.count).Should(Equal(4))
+		Ω(blue.hasRoute(next)).Should(BeTrue())
+	})
+
+	It("puts the routes back when a resumed scaleover rolls back", func() {
+		next := fakeRoute{host: "next", domain: "example.com"}
+		cf.addRoute(blue, next)
+		cf.StartLatency = 10 * time.Minute
+
+		Ω(scaleover("blue", "green", "0s", "--map-route", "next.example.com", "--start-timeout", "1m",
+			"--on-start-failure", "timeout=abort,crash=rollback")).Should(Equal(1))
+		Ω(green.hasRoute(next)).Should(BeTrue())
+
+		cf.StartLatency = 0
+		for i := range green.instances {
+			green.instances[i].crashes = true
+		}
+		Ω(scaleover("--resume")).Should(Equal(1))
+		Ω(blue.count).Should(Equal(4))
+		Ω(blue.hasRoute(next)).Should(BeTrue())
+		Ω(blue.hasRoute(shared)).Should(BeTrue())
+		Ω(green.hasRoute(next)).Should(BeFalse())
+		Ω(green.hasRoute(shared)).Should(BeTrue())
+	})
+
+	It("works out percentage batch sizes and leave counts from the source", func() {
+		Ω(scaleover("blue", "green", "0s", "--batch-size", "50%", "--leave", "10%")).Should(Equal(0))
+		Ω(blue.count).Should(Equal(1))
+		Ω(blue.state).Should(Equal("started"))
+		Ω(green.count).Should(Equal(4))
+	})
+
+	It("ends with a different number of target instances", func() {
+		Ω(scaleover("blue", "green", "0s", "--target-instances", "8")).Should(Equal(0))
+		Ω(green.count).Should(Equal(8))
+		Ω(cf.running(green)).Should(Equal(8))
+		Ω(blue.state).Should(Equal("stopped"))
+	})
+
+	It("matches the sources' memory with bigger target instances", func() {
+		green.memory = 1024
+
+		Ω(scaleover("blue", "green", "0s", "--capacity-match", "memory")).Should(Equal(0))
+		Ω(green.count).Should(Equal(1))
+		Ω(blue.state).Should(Equal("stopped"))
+	})
+
+	It("keeps to --min-available and --max-surge", func() {
+		cf.StartLatency = 10 * time.Second
+
+		Ω(scaleover("blue", "green", "0s", "--batch-size", "2", "--min-available", "50%", "--max-surge", "0")).Should(Equal(0))
+		Ω(green.count).Should(Equal(4))
+		Ω(blue.state).Should(Equal("stopped"))
+		for _, running := range cf.capacity {
+			Ω(running).Should(BeNumerically(">=", 2))
+		}
+	})
+
+	It("refuses limits it can't keep to", func() {
+		Ω(scaleover("blue", "green", "0s", "--min-available", "100%", "--max-surge", "0")).Should(Equal(1))
+		Ω(cf.commands).Should(BeEmpty())
+	})
+
+	It("moves fewer instances at a time to stay inside the space quota", func() {
+		dev.memoryLimit = 1536
+
+		Ω(scaleover("blue", "green", "0s", "--batch-size", "4")).Should(Equal(0))
+
+		Ω(cf.commands[0]).Should(Equal([]string{"curl", "/v3/apps/" + green.guid + "/processes/web/actions/scale", "-X", "POST", "-d", `{"instances":2}`}))
+		Ω(green.count).Should(Equal(4))
+		Ω(cf.spaceUsage(dev)).Should(BeNumerically("<=", 1536))
+	})
+
+	It("scales over to an app in another space", func() {
+		staging := cf.addSpace("acme", "staging")
+		canary := cf.addApp(staging, "green", 1, "stopped", 256)
+		cf.addRoute(canary, fakeRoute{host: "green", domain: "example.com"})
+
+		Ω(scaleover("blue", "acme/staging/green", "0s")).Should(Equal(0))
+
+		Ω(canary.state).Should(Equal("started"))
+		Ω(canary.count).Should(Equal(4))
+		Ω(green.state).Should(Equal("stopped"))
+		Ω(blue.state).Should(Equal("stopped"))
+	})
+
+	It("talks to the Cloud Controller directly when it has an API endpoint", func() {
+		cf.serve()
+
+		Ω(scaleover("blue", "green", "0s")).Should(Equal(0))
+
+		Ω(green.count).Should(Equal(4))
+		Ω(blue.state).Should(Equal("stopped"))
+		for _, command := range cf.commands {
+			Ω(command[0]).ShouldNot(Equal("curl"))
+		}
+	})
+})
@@ -0,0 +1,781 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/models"
+)
+
+//fakeCF is an in-memory Cloud Foundry: orgs and spaces with memory quotas, apps whose
+//instances take StartLatency to start and crash with CrashProbability, and routes.
+//It answers the plugin API, cf commands, cf curl and, once served, HTTP requests, and is
+//the clock the plugin runs by.
+type fakeCF struct {
+	StartLatency     time.Duration
+	CrashProbability float64
+
+	mu       sync.Mutex
+	clockMu  sync.Mutex
+	now      time.Time
+	random   *rand.Rand
+	orgs     []*fakeOrg
+	spaces   []*fakeSpace
+	apps     []*fakeApp
+	routes   []*fakeRoute
+	domains  []string
+	target   *fakeSpace
+	server   *httptest.Server
+	commands [][]string
+	capacity []int // running instances across every app after each change
+	guids    int
+}
+
+type fakeOrg struct {
+	guid        string
+	name        string
+	memoryLimit int64 // MB, -1 for no limit
+}
+
+type fakeSpace struct {
+	guid        string
+	name        string
+	org         *fakeOrg
+	memoryLimit int64 // MB, -1 for no limit
+}
+
+type fakeApp struct {
+	guid      string
+	name      string
+	space     *fakeSpace
+	state     string
+	count     int
+	memory    int64
+	instances []fakeInstance // empty while stopped
+	routes    []*fakeRoute
+}
+
+type fakeInstance struct {
+	since   time.Time
+	crashes bool
+}
+
+type fakeRoute struct {
+	guid   string
+	host   string
+	domain string
+	path   string
+	port   int
+	space  *fakeSpace
+}
+
+func (r *fakeRoute) same(other fakeRoute) bool {
+	return strings.EqualFold(r.host, other.host) && strings.EqualFold(r.domain, other.domain) &&
+		r.path == other.path && r.port == other.port
+}
+
+func newFakeCF() *fakeCF {
+	return &fakeCF{now: fakeEpoch, random: rand.New(rand.NewSource(1))}
+}
+
+//fakeEpoch is when every simulation starts
+var fakeEpoch = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+//Now is the simulated time, which only moves when the plugin sleeps or waits
+func (cf *fakeCF) Now() time.Time {
+	cf.clockMu.Lock()
+	defer cf.clockMu.Unlock()
+	return cf.now
+}
+
+func (cf *fakeCF) Sleep(d time.Duration) {
+	cf.clockMu.Lock()
+	defer cf.clockMu.Unlock()
+	cf.now = cf.now.Add(d)
+}
+
+func (cf *fakeCF) After(d time.Duration) <-chan time.Time {
+	cf.Sleep(d)
+	fired := make(chan time.Time, 1)
+	fired <- cf.Now()
+	return fired
+}
+
+func (cf *fakeCF) elapsed() time.Duration {
+	return cf.Now().Sub(fakeEpoch)
+}
+
+func (cf *fakeCF) newGUID(kind string) string {
+	cf.guids++
+	return fmt.Sprintf("%s-guid-%d", kind, cf.guids)
+}
+
+//addSpace creates the space, and its org if needed. The first space is the targeted one.
+func (cf *fakeCF) addSpace(orgName string, name string) *fakeSpace {
+	var org *fakeOrg
+	for _, o := range cf.orgs {
+		if o.name == orgName {
+			org = o
+		}
+	}
+	if org == nil {
+		org = &fakeOrg{guid: cf.newGUID("org"), name: orgName, memoryLimit: -1}
+		cf.orgs = append(cf.orgs, org)
+	}
+
+	space := &fakeSpace{guid: cf.newGUID("space"), name: name, org: org, memoryLimit: -1}
+	cf.spaces = append(cf.spaces, space)
+	if cf.target == nil {
+		cf.target = space
+	}
+	return space
+}
+
+//addApp creates an app with count instances, already running if it's started
+func (cf *fakeCF) addApp(space *fakeSpace, name string, count int, state string, memory int64) *fakeApp {
+	app := &fakeApp{guid: cf.newGUID("app"), name: name, space: space, state: state, count: count, memory: memory}
+	if state == "started" {
+		for i := 0; i < count; i++ {
+			app.instances = append(app.instances, fakeInstance{})
+		}
+	}
+	cf.apps = append(cf.apps, app)
+	return app
+}
+
+//addRoute maps the route to the app, creating it in the app's space if it doesn't exist
+func (cf *fakeCF) addRoute(app *fakeApp, r fakeRoute) {
+	cf.mapRoute(app, cf.findOrCreateRoute(app.space, r))
+}
+
+func (cf *fakeCF) findOrCreateRoute(space *fakeSpace, r fakeRoute) *fakeRoute {
+	for _, existing := range cf.routes {
+		if existing.same(r) {
+			return existing
+		}
+	}
+	r.guid, r.space = cf.newGUID("route"), space
+	created := &r
+	cf.routes = append(cf.routes, created)
+	cf.addDomain(r.domain)
+	return created
+}
+
+func (cf *fakeCF) addDomain(domain string) {
+	for _, d := range cf.domains {
+		if d == domain {
+			return
+		}
+	}
+	cf.domains = append(cf.domains, domain)
+}
+
+func (cf *fakeCF) mapRoute(app *fakeApp, r *fakeRoute) {
+	for _, mapped := range app.routes {
+		if mapped == r {
+			return
+		}
+	}
+	app.routes = append(app.routes, r)
+}
+
+//hasRoute is true when the app is mapped to a route like r
+func (app *fakeApp) hasRoute(r fakeRoute) bool {
+	for _, mapped := range app.routes {
+		if mapped.same(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cf *fakeCF) unmapRoute(app *fakeApp, r *fakeRoute) {
+	var routes []*fakeRoute
+	for _, mapped := range app.routes {
+		if mapped != r {
+			routes = append(routes, mapped)
+		}
+	}
+	app.routes = routes
+}
+
+//serve answers HTTP requests too, and gives the plugin the address as the API endpoint
+func (cf *fakeCF) serve() {
+	cf.server = httptest.NewServer(cf)
+}
+
+func (cf *fakeCF) close() {
+	if cf.server != nil {
+		cf.server.Close()
+	}
+}
+
+//instanceState is STARTING until StartLatency has passed, then RUNNING or CRASHED
+func (cf *fakeCF) instanceState(instance fakeInstance) string {
+	switch {
+	case cf.Now().Sub(instance.since) < cf.StartLatency:
+		return "STARTING"
+	case instance.crashes:
+		return "CRASHED"
+	}
+	return "RUNNING"
+}
+
+func (cf *fakeCF) running(app *fakeApp) int {
+	running := 0
+	for _, instance := range app.instances {
+		if cf.instanceState(instance) == "RUNNING" {
+			running++
+		}
+	}
+	return running
+}
+
+//recordCapacity notes how many instances are serving across every app
+func (cf *fakeCF) recordCapacity() {
+	total := 0
+	for _, app := range cf.apps {
+		total += cf.running(app)
+	}
+	cf.capacity = append(cf.capacity, total)
+}
+
+func (cf *fakeCF) newInstance() fakeInstance {
+	return fakeInstance{since: cf.Now(), crashes: cf.random.Float64() < cf.CrashProbability}
+}
+
+//scale changes the instance count, refusing when a started app would go over a quota
+func (cf *fakeCF) scale(app *fakeApp, count int) error {
+	if app.state == "started" {
+		if err := cf.checkQuota(app, count); err != nil {
+			return err
+		}
+		for len(app.instances) < count {
+			app.instances = append(app.instances, cf.newInstance())
+		}
+		app.instances = app.instances[:count]
+	}
+	app.count = count
+	return nil
+}
+
+func (cf *fakeCF) start(app *fakeApp) error {
+	if app.state == "started" {
+		return nil
+	}
+	if err := cf.checkQuota(app, app.count); err != nil {
+		return err
+	}
+	app.state = "started"
+	for i := 0; i < app.count; i++ {
+		app.instances = append(app.instances, cf.newInstance())
+	}
+	return nil
+}
+
+func (cf *fakeCF) stop(app *fakeApp) {
+	app.state = "stopped"
+	app.instances = nil
+}
+
+//checkQuota errors when running count instances of the app would use more memory than its space or org allows
+func (cf *fakeCF) checkQuota(app *fakeApp, count int) error {
+	extra := int64(count-len(app.instances)) * app.memory
+	if limit := app.space.memoryLimit; limit >= 0 && cf.spaceUsage(app.space)+extra > limit {
+		return errors.New("You have exceeded the memory limit for this space.")
+	}
+	if limit := app.space.org.memoryLimit; limit >= 0 && cf.orgUsage(app.space.org)+extra > limit {
+		return errors.New("You have exceeded your organization's memory limit.")
+	}
+	return nil
+}
+
+func (cf *fakeCF) spaceUsage(space *fakeSpace) int64 {
+	var used int64
+	for _, app := range cf.apps {
+		if app.space == space {
+			used += int64(len(app.instances)) * app.memory
+		}
+	}
+	return used
+}
+
+func (cf *fakeCF) orgUsage(org *fakeOrg) int64 {
+	var used int64
+	for _, space := range cf.spaces {
+		if space.org == org {
+			used += cf.spaceUsage(space)
+		}
+	}
+	return used
+}
+
+func (cf *fakeCF) findApp(space *fakeSpace, name string) *fakeApp {
+	for _, app := range cf.apps {
+		if app.space == space && app.name == name {
+			return app
+		}
+	}
+	return nil
+}
+
+func (cf *fakeCF) appByGUID(guid string) *fakeApp {
+	for _, app := range cf.apps {
+		if app.guid == guid {
+			return app
+		}
+	}
+	return nil
+}
+
+func (cf *fakeCF) routeByGUID(guid string) *fakeRoute {
+	for _, r := range cf.routes {
+		if r.guid == guid {
+			return r
+		}
+	}
+	return nil
+}
+
+//CliCommandWithoutTerminalOutput runs scale, start, stop, map-route, unmap-route and curl
+func (cf *fakeCF) CliCommandWithoutTerminalOutput(args ...string) ([]string, error) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	cf.commands = append(cf.commands, args)
+
+	if args[0] == "curl" {
+		method, body := "GET", ""
+		for i := 2; i < len(args)-1; i++ {
+			switch args[i] {
+			case "-X":
+				method = args[i+1]
+			case "-d":
+				body = args[i+1]
+			}
+		}
+		// like cf curl, a refused request still succeeds and prints the error
+		_, response := cf.request(method, args[1], body)
+		return []string{response}, nil
+	}
+
+	err := cf.command(args)
+	if err != nil {
+		return []string{"FAILED", err.Error()}, errors.New("Error executing cli core command")
+	}
+	cf.recordCapacity()
+	return []string{"OK"}, nil
+}
+
+func (cf *fakeCF) command(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("Incorrect usage of cf %s", strings.Join(args, " "))
+	}
+	name := args[len(args)-1]
+	if args[0] == "map-route" || args[0] == "unmap-route" {
+		name = args[1]
+	}
+	app := cf.findApp(cf.target, name)
+	if app == nil {
+		return fmt.Errorf("App %s not found", name)
+	}
+
+	switch args[0] {
+	case "scale":
+		count, err := strconv.Atoi(args[2])
+		if err != nil {
+			return err
+		}
+		return cf.scale(app, count)
+	case "start":
+		return cf.start(app)
+	case "stop":
+		cf.stop(app)
+		return nil
+	case "map-route", "unmap-route":
+		r := fakeRoute{domain: args[2]}
+		for i := 3; i < len(args)-1; i++ {
+			switch args[i] {
+			case "--hostname":
+				r.host = args[i+1]
+			case "--path":
+				r.path = args[i+1]
+			case "--port":
+				r.port, _ = strconv.Atoi(args[i+1])
+			}
+		}
+		if args[0] == "map-route" {
+			cf.mapRoute(app, cf.findOrCreateRoute(app.space, r))
+			return nil
+		}
+		for _, mapped := range app.routes {
+			if mapped.same(r) {
+				cf.unmapRoute(app, mapped)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("'%s' is not a registered command", args[0])
+}
+
+//ServeHTTP answers the same requests as cf curl
+func (cf *fakeCF) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if r.Header.Get("Authorization") == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errors": [{"code": 10002, "title": "CF-NotAuthenticated", "detail": "Authentication error"}]}`))
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	status, response := cf.request(r.Method, r.URL.RequestURI(), string(body))
+	w.WriteHeader(status)
+	w.Write([]byte(response))
+}
+
+//request answers the parts of the v2 and v3 APIs the plugin uses
+func (cf *fakeCF) request(method string, uri string, body string) (int, string) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return v3Failure(http.StatusBadRequest, "CF-InvalidRequest", err.Error())
+	}
+	query := map[string]string{}
+	for _, q := range u.Query()["q"] {
+		if colon := strings.Index(q, ":"); colon >= 0 {
+			query[q[:colon]] = q[colon+1:]
+		}
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	path := strings.Join(parts, "/")
+
+	switch {
+	case method == "GET" && path == "v2/organizations":
+		var guids []string
+		for _, org := range cf.orgs {
+			if org.name == query["name"] {
+				guids = append(guids, org.guid)
+			}
+		}
+		return v2Page(guids)
+	case method == "GET" && len(parts) == 4 && parts[0] == "v2" && parts[1] == "organizations" && parts[3] == "spaces":
+		var guids []string
+		for _, space := range cf.spaces {
+			if space.org.guid == parts[2] && space.name == query["name"] {
+				guids = append(guids, space.guid)
+			}
+		}
+		return v2Page(guids)
+	case method == "GET" && len(parts) == 4 && parts[0] == "v2" && parts[1] == "organizations" && parts[3] == "memory_usage":
+		for _, org := range cf.orgs {
+			if org.guid == parts[2] {
+				return jsonResponse(http.StatusOK, map[string]int64{"memory_usage_in_mb": cf.orgUsage(org)})
+			}
+		}
+	case method == "GET" && len(parts) == 4 && parts[0] == "v2" && parts[1] == "spaces" && parts[3] == "apps":
+		var guids []string
+		for _, app := range cf.apps {
+			if app.space.guid == parts[2] && app.name == query["name"] {
+				guids = append(guids, app.guid)
+			}
+		}
+		return v2Page(guids)
+	case method == "GET" && len(parts) == 4 && parts[0] == "v2" && parts[1] == "apps" && parts[3] == "summary":
+		if app := cf.appByGUID(parts[2]); app != nil {
+			return jsonResponse(http.StatusOK, cf.summary(app))
+		}
+	case method == "GET" && path == "v2/domains":
+		var guids []string
+		for _, domain := range cf.domains {
+			if domain == query["name"] {
+				guids = append(guids, "domain-"+domain)
+			}
+		}
+		return v2Page(guids)
+	case method == "GET" && path == "v2/routes":
+		var guids []string
+		for _, r := range cf.routes {
+			if "domain-"+r.domain == query["domain_guid"] && r.host == query["host"] &&
+				r.path == query["path"] && strconv.Itoa(r.port) == defaultString(query["port"], "0") {
+				guids = append(guids, r.guid)
+			}
+		}
+		return v2Page(guids)
+	case (method == "PUT" || method == "DELETE") && len(parts) == 5 && parts[0] == "v2" && parts[1] == "routes" && parts[3] == "apps":
+		r, app := cf.routeByGUID(parts[2]), cf.appByGUID(parts[4])
+		if r == nil || app == nil {
+			return v2Failure(http.StatusNotFound, 10000, "CF-NotFound", "Unknown request")
+		}
+		if r.space != app.space {
+			return v2Failure(http.StatusBadRequest, 1002, "CF-InvalidRelation", "The app cannot be mapped to a route in a different space")
+		}
+		if method == "PUT" {
+			cf.mapRoute(app, r)
+		} else {
+			cf.unmapRoute(app, r)
+		}
+		return http.StatusCreated, "{}"
+	case method == "POST" && len(parts) == 7 && parts[0] == "v3" && parts[1] == "apps" && strings.Join(parts[3:], "/") == "processes/web/actions/scale":
+		app := cf.appByGUID(parts[2])
+		if app == nil {
+			break
+		}
+		var scale struct {
+			Instances int `json:"instances"`
+		}
+		if err := json.Unmarshal([]byte(body), &scale); err != nil {
+			return v3Failure(http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		}
+		return cf.changed(cf.scale(app, scale.Instances))
+	case method == "POST" && len(parts) == 5 && parts[0] == "v3" && parts[1] == "apps" && parts[3] == "actions":
+		app := cf.appByGUID(parts[2])
+		if app == nil {
+			break
+		}
+		switch parts[4] {
+		case "start":
+			return cf.changed(cf.start(app))
+		case "stop":
+			cf.stop(app)
+			return cf.changed(nil)
+		}
+	case method == "GET" && len(parts) == 6 && parts[0] == "v3" && parts[1] == "apps" && strings.Join(parts[3:], "/") == "processes/web/stats":
+		app := cf.appByGUID(parts[2])
+		if app == nil {
+			break
+		}
+		stats := []map[string]interface{}{}
+		for i, instance := range app.instances {
+			stats = append(stats, map[string]interface{}{"type": "web", "index": i, "state": cf.instanceState(instance)})
+		}
+		return jsonResponse(http.StatusOK, map[string]interface{}{"resources": stats})
+	}
+	return v3Failure(http.StatusNotFound, "CF-ResourceNotFound", "Unknown request")
+}
+
+//changed answers a v3 action, recording the capacity when it worked
+func (cf *fakeCF) changed(err error) (int, string) {
+	if err != nil {
+		return v3Failure(http.StatusUnprocessableEntity, "CF-UnprocessableEntity", err.Error())
+	}
+	cf.recordCapacity()
+	return http.StatusAccepted, "{}"
+}
+
+func (cf *fakeCF) summary(app *fakeApp) map[string]interface{} {
+	routes := []map[string]interface{}{}
+	for _, r := range app.routes {
+		routes = append(routes, map[string]interface{}{
+			"guid": r.guid, "host": r.host, "path": r.path, "port": r.port,
+			"domain": map[string]string{"name": r.domain},
+		})
+	}
+	return map[string]interface{}{
+		"guid":              app.guid,
+		"space_guid":        app.space.guid,
+		"state":             strings.ToUpper(app.state),
+		"instances":         app.count,
+		"running_instances": cf.running(app),
+		"memory":            app.memory,
+		"routes":            routes,
+	}
+}
+
+func v2Page(guids []string) (int, string) {
+	page := `{"resources": [`
+	for i, guid := range guids {
+		if i > 0 {
+			page += ", "
+		}
+		page += `{"metadata": {"guid": "` + guid + `"}}`
+	}
+	return http.StatusOK, page + `]}`
+}
+
+func v2Failure(status int, code int, errorCode string, description string) (int, string) {
+	return jsonResponse(status, map[string]interface{}{"code": code, "error_code": errorCode, "description": description})
+}
+
+func v3Failure(status int, title string, detail string) (int, string) {
+	return jsonResponse(status, map[string][]map[string]interface{}{
+		"errors": {{"code": 10000, "title": title, "detail": detail}},
+	})
+}
+
+func jsonResponse(status int, v interface{}) (int, string) {
+	data, _ := json.Marshal(v)
+	return status, string(data)
+}
+
+func defaultString(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func (cf *fakeCF) CliCommand(args ...string) ([]string, error) {
+	return cf.CliCommandWithoutTerminalOutput(args...)
+}
+
+func (cf *fakeCF) GetApp(name string) (plugin_models.GetAppModel, error) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	app := cf.findApp(cf.target, name)
+	if app == nil {
+		return plugin_models.GetAppModel{}, fmt.Errorf("App %s not found", name)
+	}
+	model := plugin_models.GetAppModel{
+		Guid:             app.guid,
+		Name:             app.name,
+		SpaceGuid:        app.space.guid,
+		State:            app.state,
+		InstanceCount:    app.count,
+		RunningInstances: cf.running(app),
+		Memory:           app.memory,
+	}
+	for _, instance := range app.instances {
+		model.Instances = append(model.Instances, plugin_models.GetApp_AppInstanceFields{State: strings.ToLower(cf.instanceState(instance))})
+	}
+	for _, r := range app.routes {
+		model.Routes = append(model.Routes, plugin_models.GetApp_RouteSummary{
+			Guid: r.guid, Host: r.host, Path: r.path, Port: r.port,
+			Domain: plugin_models.GetApp_DomainFields{Guid: "domain-" + r.domain, Name: r.domain},
+		})
+	}
+	return model, nil
+}
+
+func (cf *fakeCF) GetApps() ([]plugin_models.GetAppsModel, error) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	var apps []plugin_models.GetAppsModel
+	for _, app := range cf.apps {
+		if app.space == cf.target {
+			apps = append(apps, plugin_models.GetAppsModel{Name: app.name, Guid: app.guid, State: app.state,
+				TotalInstances: app.count, RunningInstances: cf.running(app), Memory: app.memory})
+		}
+	}
+	return apps, nil
+}
+
+func (cf *fakeCF) GetCurrentOrg() (plugin_models.Organization, error) {
+	org := plugin_models.Organization{}
+	org.Guid, org.Name = cf.target.org.guid, cf.target.org.name
+	return org, nil
+}
+
+func (cf *fakeCF) GetCurrentSpace() (plugin_models.Space, error) {
+	space := plugin_models.Space{}
+	space.Guid, space.Name = cf.target.guid, cf.target.name
+	return space, nil
+}
+
+func (cf *fakeCF) GetOrg(name string) (plugin_models.GetOrg_Model, error) {
+	for _, org := range cf.orgs {
+		if org.name == name {
+			model := plugin_models.GetOrg_Model{Guid: org.guid, Name: org.name}
+			if org.memoryLimit >= 0 {
+				model.QuotaDefinition = plugin_models.QuotaFields{Guid: org.guid + "-quota", Name: org.name + "-quota", MemoryLimit: org.memoryLimit}
+			}
+			return model, nil
+		}
+	}
+	return plugin_models.GetOrg_Model{}, fmt.Errorf("Organization %s not found", name)
+}
+
+func (cf *fakeCF) GetSpace(name string) (plugin_models.GetSpace_Model, error) {
+	for _, space := range cf.spaces {
+		if space.org == cf.target.org && space.name == name {
+			model := plugin_models.GetSpace_Model{}
+			model.Guid, model.Name = space.guid, space.name
+			for _, domain := range cf.domains {
+				model.Domains = append(model.Domains, plugin_models.GetSpace_Domains{Guid: "domain-" + domain, Name: domain})
+			}
+			if space.memoryLimit >= 0 {
+				model.SpaceQuota = plugin_models.GetSpace_SpaceQuota{Guid: space.guid + "-quota", Name: space.name + "-quota", MemoryLimit: space.memoryLimit}
+			}
+			return model, nil
+		}
+	}
+	return plugin_models.GetSpace_Model{}, fmt.Errorf("Space %s not found", name)
+}
+
+func (cf *fakeCF) ApiEndpoint() (string, error) {
+	if cf.server == nil {
+		return "", nil
+	}
+	return cf.server.URL, nil
+}
+
+func (cf *fakeCF) AccessToken() (string, error)         { return "bearer fake-token", nil }
+func (cf *fakeCF) Username() (string, error)            { return "admin", nil }
+func (cf *fakeCF) UserGuid() (string, error)            { return "user-guid", nil }
+func (cf *fakeCF) UserEmail() (string, error)           { return "admin@example.com", nil }
+func (cf *fakeCF) IsLoggedIn() (bool, error)            { return true, nil }
+func (cf *fakeCF) IsSSLDisabled() (bool, error)         { return false, nil }
+func (cf *fakeCF) HasOrganization() (bool, error)       { return cf.target != nil, nil }
+func (cf *fakeCF) HasSpace() (bool, error)              { return cf.target != nil, nil }
+func (cf *fakeCF) ApiVersion() (string, error)          { return "2.100.0", nil }
+func (cf *fakeCF) HasAPIEndpoint() (bool, error)        { return cf.server != nil, nil }
+func (cf *fakeCF) LoggregatorEndpoint() (string, error) { return "", nil }
+func (cf *fakeCF) DopplerEndpoint() (string, error)     { return "", nil }
+
+func (cf *fakeCF) GetOrgs() ([]plugin_models.GetOrgs_Model, error) {
+	var orgs []plugin_models.GetOrgs_Model
+	for _, org := range cf.orgs {
+		orgs = append(orgs, plugin_models.GetOrgs_Model{Guid: org.guid, Name: org.name})
+	}
+	return orgs, nil
+}
+
+func (cf *fakeCF) GetSpaces() ([]plugin_models.GetSpaces_Model, error) {
+	var spaces []plugin_models.GetSpaces_Model
+	for _, space := range cf.spaces {
+		if space.org == cf.target.org {
+			spaces = append(spaces, plugin_models.GetSpaces_Model{Guid: space.guid, Name: space.name})
+		}
+	}
+	return spaces, nil
+}
+
+func (cf *fakeCF) GetOrgUsers(string, ...string) ([]plugin_models.GetOrgUsers_Model, error) {
+	return nil, nil
+}
+
+func (cf *fakeCF) GetSpaceUsers(string, string) ([]plugin_models.GetSpaceUsers_Model, error) {
+	return nil, nil
+}
+
+func (cf *fakeCF) GetServices() ([]plugin_models.GetServices_Model, error) {
+	return nil, nil
+}
+
+func (cf *fakeCF) GetService(name string) (plugin_models.GetService_Model, error) {
+	return plugin_models.GetService_Model{}, fmt.Errorf("Service instance %s not found", name)
+}
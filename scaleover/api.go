@@ -0,0 +1,195 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+//apiRequestTimeout bounds a single Cloud Controller request
+const apiRequestTimeout = 30 * time.Second
+
+//ccClient talks to the Cloud Controller directly with the cf CLI's token,
+//rather than running a cf command for every request
+type ccClient struct {
+	endpoint string
+	token    func() (string, error) // the CLI refreshes the token when it has expired
+	http     *http.Client
+}
+
+//apiError is a request the Cloud Controller refused, with the reasons it gave
+type apiError struct {
+	Method  string
+	Path    string
+	Status  int
+	Code    int
+	Title   string // CF-AppMemoryQuotaExceeded and the like
+	Details []string
+}
+
+func (e *apiError) Error() string {
+	reason := strings.Join(e.Details, ", ")
+	if reason == "" {
+		reason = http.StatusText(e.Status)
+	}
+	return fmt.Sprintf("%s %s failed with status %d: %s", e.Method, e.Path, e.Status, reason)
+}
+
+func newCCClient(cliConnection plugin.CliConnection) (*ccClient, error) {
+	endpoint, err := cliConnection.ApiEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("No API endpoint set, use cf login")
+	}
+	insecure, err := cliConnection.IsSSLDisabled()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ccClient{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		token:    cliConnection.AccessToken,
+		http: &http.Client{
+			Timeout:   apiRequestTimeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}},
+		},
+	}, nil
+}
+
+//processStats is what /v3/apps/:guid/processes/web/stats says about each instance
+type processStats struct {
+	Resources []struct {
+		Type  string `json:"type"`
+		Index int    `json:"index"`
+		State string `json:"state"`
+	} `json:"resources"`
+}
+
+//stats reads the state of each instance of the app's web process
+func (c *ccClient) stats(appGUID string) (processStats, error) {
+	var stats processStats
+	err := c.do("GET", statsPath(appGUID), "", &stats)
+	return stats, err
+}
+
+//curl runs a request written as cf curl arguments: curl PATH [-X METHOD] [-d BODY]
+func (c *ccClient) curl(args []string) error {
+	method, body := "GET", ""
+	for i := 2; i < len(args)-1; i++ {
+		switch args[i] {
+		case "-X":
+			method = args[i+1]
+		case "-d":
+			body = args[i+1]
+			if method == "GET" {
+				method = "POST"
+			}
+		}
+	}
+	return c.do(method, args[1], body, nil)
+}
+
+//do makes one request, decoding a successful response into v when it's not nil
+func (c *ccClient) do(method string, path string, body string, v interface{}) error {
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Accept", "application/json")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return newAPIError(method, path, resp.StatusCode, data)
+	}
+	if v == nil || len(data) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("Unexpected response from %s %s: %s", method, path, err)
+	}
+	return nil
+}
+
+//newAPIError reads the v3 errors list, or the older v2 single error
+func newAPIError(method string, path string, status int, data []byte) *apiError {
+	e := &apiError{Method: method, Path: path, Status: status}
+
+	var v3 struct {
+		Errors []struct {
+			Code   int    `json:"code"`
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+		} `json:"errors"`
+	}
+	var v2 v2Error
+	if json.Unmarshal(data, &v3) == nil && len(v3.Errors) > 0 {
+		e.Code, e.Title = v3.Errors[0].Code, v3.Errors[0].Title
+		for _, detail := range v3.Errors {
+			e.Details = append(e.Details, detail.Detail)
+		}
+	} else if json.Unmarshal(data, &v2) == nil && v2.ErrorCode != "" {
+		e.Code, e.Title, e.Details = v2.Code, v2.ErrorCode, []string{v2.Description}
+	}
+	return e
+}
+
+//scalePath scales the app's web process
+func scalePath(appGUID string) string {
+	return "/v3/apps/" + appGUID + "/processes/web/actions/scale"
+}
+
+//actionPath starts or stops the app
+func actionPath(appGUID string, action string) string {
+	return "/v3/apps/" + appGUID + "/actions/" + action
+}
+
+//statsPath is the stats of the app's web process. It's looked up through the app because the
+//web process gets a guid of its own after a rolling deployment.
+func statsPath(appGUID string) string {
+	return "/v3/apps/" + appGUID + "/processes/web/stats"
+}
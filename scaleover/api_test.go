@@ -0,0 +1,121 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cloud Controller client", func() {
+	var server *httptest.Server
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var client *ccClient
+	var requests []string
+	var tokens []string
+	var status int
+	var response string
+
+	BeforeEach(func() {
+		requests, tokens = nil, nil
+		status, response = http.StatusAccepted, "{}"
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+			tokens = append(tokens, r.Header.Get("Authorization"))
+			w.WriteHeader(status)
+			w.Write([]byte(response))
+		}))
+
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.ApiEndpointReturns(server.URL+"/", nil)
+		fakeCliConnection.AccessTokenReturns("bearer some-token", nil)
+		var err error
+		client, err = newCCClient(fakeCliConnection)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("scales and starts through the v3 API with the CLI's token", func() {
+		app := &AppStatus{name: "green", guid: "green-guid", state: "stopped", client: client}
+		Ω(app.scaleUp(fakeCliConnection, 3)).Should(Succeed())
+
+		Ω(requests).Should(Equal([]string{
+			`POST /v3/apps/green-guid/processes/web/actions/scale {"instances":3}`,
+			"POST /v3/apps/green-guid/actions/start ",
+		}))
+		Ω(tokens).Should(ConsistOf("bearer some-token", "bearer some-token"))
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).Should(Equal(0))
+	})
+
+	It("stops through the v3 API", func() {
+		app := &AppStatus{name: "blue", guid: "blue-guid", state: "started", countRequested: 2, client: client}
+		Ω(app.scaleDown(fakeCliConnection, 0)).Should(Succeed())
+		Ω(requests[0]).Should(Equal("POST /v3/apps/blue-guid/actions/stop "))
+	})
+
+	It("reads instance states from the process stats", func() {
+		status = http.StatusOK
+		response = `{"resources": [{"type": "web", "index": 1, "state": "CRASHED"}, {"type": "web", "index": 0, "state": "RUNNING"}]}`
+		app := &AppStatus{name: "green", guid: "green-guid", client: client}
+
+		Ω(app.instanceStates(fakeCliConnection)).Should(Equal([]string{"RUNNING", "CRASHED"}))
+		Ω(requests).Should(Equal([]string{"GET /v3/apps/green-guid/processes/web/stats "}))
+		Ω(app.waitForStart(fakeCliConnection, 0)).Should(MatchError("green has crashed instances"))
+	})
+
+	It("returns the Cloud Controller's errors", func() {
+		status = http.StatusUnprocessableEntity
+		response = `{"errors": [{"code": 10008, "title": "CF-UnprocessableEntity", "detail": "memory quota_exceeded"}]}`
+
+		err := client.curl([]string{"curl", scalePath("green-guid"), "-X", "POST", "-d", `{"instances":30}`})
+		apiErr, ok := err.(*apiError)
+		Ω(ok).Should(BeTrue())
+		Ω(apiErr.Status).Should(Equal(http.StatusUnprocessableEntity))
+		Ω(apiErr.Title).Should(Equal("CF-UnprocessableEntity"))
+		Ω(err).Should(MatchError("POST /v3/apps/green-guid/processes/web/actions/scale failed with status 422: memory quota_exceeded"))
+	})
+
+	It("reads the older v2 errors too", func() {
+		status = http.StatusNotFound
+		response = `{"code": 100004, "description": "The app could not be found: nope", "error_code": "CF-AppNotFound"}`
+
+		err := client.curl([]string{"curl", "/v2/routes/route-guid/apps/nope", "-X", "PUT"})
+		Ω(err).Should(MatchError("PUT /v2/routes/route-guid/apps/nope failed with status 404: The app could not be found: nope"))
+		Ω(err.(*apiError).Title).Should(Equal("CF-AppNotFound"))
+	})
+
+	It("retries failed requests like failed cf commands", func() {
+		status = http.StatusServiceUnavailable
+		app := &AppStatus{name: "green", guid: "green-guid", state: "started", client: client,
+			retry: RetryPolicy{Retries: 1}}
+		Ω(app.scaleUp(fakeCliConnection, 2)).ShouldNot(Succeed())
+		Ω(requests).Should(HaveLen(2))
+	})
+
+	It("needs an API endpoint", func() {
+		fakeCliConnection.ApiEndpointReturns("", nil)
+		_, err := newCCClient(fakeCliConnection)
+		Ω(err).Should(MatchError("No API endpoint set, use cf login"))
+	})
+})
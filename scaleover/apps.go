@@ -0,0 +1,139 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import "strings"
+
+//shares splits total instances between apps in proportion to their weights. Each instance
+//goes to the app furthest below its share, so as total grows no app's share ever shrinks.
+func shares(total int, weights []int) []int {
+	counts := make([]int, len(weights))
+	if len(weights) == 0 {
+		return counts
+	}
+	for n := 0; n < total; n++ {
+		best := 0
+		for i, weight := range weights {
+			// weight/(counts+1) > best's, without dividing
+			if weight*(counts[best]+1) > weights[best]*(counts[i]+1) {
+				best = i
+			}
+		}
+		counts[best]++
+	}
+	return counts
+}
+
+//apps is every source followed by every target
+func (cmd *Scaleover) apps() []*AppStatus {
+	apps := make([]*AppStatus, 0, len(cmd.sources)+len(cmd.targets))
+	apps = append(apps, cmd.sources...)
+	return append(apps, cmd.targets...)
+}
+
+//counts is each app's requested instances
+func counts(apps []*AppStatus) []int {
+	list := make([]int, len(apps))
+	for i, app := range apps {
+		list[i] = app.countRequested
+	}
+	return list
+}
+
+func requested(apps []*AppStatus) int {
+	total := 0
+	for _, app := range apps {
+		total += app.countRequested
+	}
+	return total
+}
+
+func running(apps []*AppStatus) int {
+	total := 0
+	for _, app := range apps {
+		total += app.countRunning
+	}
+	return total
+}
+
+func names(apps []*AppStatus) string {
+	list := make([]string, len(apps))
+	for i, app := range apps {
+		list[i] = app.name
+	}
+	return strings.Join(list, ",")
+}
+
+//state is the apps' shared state, or mixed when they differ
+func state(apps []*AppStatus) string {
+	for _, app := range apps {
+		if app.state != apps[0].state {
+			return "mixed"
+		}
+	}
+	if len(apps) == 0 {
+		return "unknown"
+	}
+	return apps[0].state
+}
+
+//copyApps lets a plan be worked out without changing the apps themselves
+func copyApps(apps []*AppStatus) []*AppStatus {
+	copies := make([]*AppStatus, len(apps))
+	for i, app := range apps {
+		c := *app
+		copies[i] = &c
+	}
+	return copies
+}
+
+func snapshot(apps []*AppStatus) []AppStatus {
+	snapshots := make([]AppStatus, len(apps))
+	for i, app := range apps {
+		snapshots[i] = *app
+	}
+	return snapshots
+}
+
+//sourceCounts splits a step's source total between the sources in proportion to
+//how many instances each had when the scaleover started
+func (cmd *Scaleover) sourceCounts(total int) []int {
+	weights := make([]int, len(cmd.sources))
+	for i := range cmd.sources {
+		if i < len(cmd.origSources) {
+			weights[i] = cmd.origSources[i].countRequested
+		}
+	}
+	return shares(total, weights)
+}
+
+//targetCounts splits a step's target total between the targets by weight
+func (cmd *Scaleover) targetCounts(total int) []int {
+	weights := make([]int, len(cmd.targets))
+	for i, app := range cmd.targets {
+		weights[i] = atLeastOne(app.weight)
+	}
+	return shares(total, weights)
+}
+
+//needsScaleUp is false when the target already has n instances or more, targets are never scaled down mid-rollout
+func (app *AppStatus) needsScaleUp(n int) bool {
+	return n > 0 && (app.state == "stopped" || n > app.countRequested)
+}
+
+//needsScaleDown is false when the source already has n instances or fewer
+func (app *AppStatus) needsScaleDown(n int) bool {
+	return app.state != "stopped" && n < app.countRequested
+}
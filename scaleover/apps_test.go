@@ -0,0 +1,91 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Several sources and targets", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+
+	BeforeEach(func() {
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{
+				{name: "blue", countRequested: 6, countRunning: 6, state: "started"},
+				{name: "red", countRequested: 4, countRunning: 4, state: "started"},
+			},
+			targets: []*AppStatus{{name: "green", state: "stopped", weight: 1}},
+		}
+		scaleoverCmdPlugin.origSources = snapshot(scaleoverCmdPlugin.sources)
+		scaleoverCmdPlugin.origTargets = snapshot(scaleoverCmdPlugin.targets)
+	})
+
+	It("splits instances by weight without any app's share ever shrinking", func() {
+		Ω(shares(4, []int{3, 1})).Should(Equal([]int{3, 1}))
+		Ω(shares(10, []int{6, 4})).Should(Equal([]int{6, 4}))
+		last := []int{0, 0, 0}
+		for n := 0; n <= 20; n++ {
+			next := shares(n, []int{5, 3, 2})
+			for i := range next {
+				Ω(next[i]).Should(BeNumerically(">=", last[i]))
+			}
+			last = next
+		}
+	})
+
+	It("drains the sources in proportion to their original counts", func() {
+		Ω(scaleoverCmdPlugin.sourceCounts(5)).Should(Equal([]int{3, 2}))
+
+		Ω(scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 2})).Should(Succeed())
+		Ω(scaleoverCmdPlugin.targets[0].countRequested).Should(Equal(10))
+		Ω(scaleoverCmdPlugin.sources[0].state).Should(Equal("stopped"))
+		Ω(scaleoverCmdPlugin.sources[1].state).Should(Equal("stopped"))
+	})
+
+	It("fills weighted targets", func() {
+		scaleoverCmdPlugin.sources = scaleoverCmdPlugin.sources[:1]
+		scaleoverCmdPlugin.sources[0].countRequested = 8
+		scaleoverCmdPlugin.targets = []*AppStatus{
+			{name: "green", state: "stopped", weight: 3},
+			{name: "canary", state: "stopped", weight: 1},
+		}
+
+		Ω(scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 4})).Should(Succeed())
+		Ω(scaleoverCmdPlugin.targets[0].countRequested).Should(Equal(6))
+		Ω(scaleoverCmdPlugin.targets[1].countRequested).Should(Equal(2))
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)).Should(Equal([]string{"scale", "-i", "3", "green"}))
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(2)).Should(Equal([]string{"scale", "-i", "1", "canary"}))
+	})
+
+	It("needs a route shared by every app", func() {
+		shared := route{host: "www", domain: "example.com"}
+		scaleoverCmdPlugin.sources[0].routes = []route{shared}
+		scaleoverCmdPlugin.sources[1].routes = []route{shared}
+		scaleoverCmdPlugin.targets[0].routes = []route{{host: "green", domain: "example.com"}}
+		Ω(scaleoverCmdPlugin.appsShareARoute()).Should(BeFalse())
+
+		scaleoverCmdPlugin.targets[0].routes = append(scaleoverCmdPlugin.targets[0].routes, shared)
+		Ω(scaleoverCmdPlugin.appsShareARoute()).Should(BeTrue())
+	})
+
+	It("shows the sources and targets together", func() {
+		Ω(statusLine(scaleoverCmdPlugin.sources, scaleoverCmdPlugin.targets)).Should(Equal("blue,red (started) 10 instances, green (stopped) 0 instances"))
+	})
+})
@@ -0,0 +1,53 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+//Values accepted by --balance
+const (
+	//BalancePlan scales the sources down by the plan, whether or not the new instances came up
+	BalancePlan = "plan"
+	//BalanceReady scales the sources down only by as many target instances as are running
+	BalanceReady = "ready"
+)
+
+//ValidBalance is true for the values --balance accepts
+func ValidBalance(balance string) bool {
+	return balance == BalancePlan || balance == BalanceReady
+}
+
+//readySourceTotal is how many source instances to keep after a step planned to keep planned,
+//so that the sources only shrink by as many target instances as have become running since
+//the scaleover started, scaled when the targets end with target instances rather than as
+//many as the sources had (target 0). It's never below planned, and never more than the sources have now.
+func (cmd *Scaleover) readySourceTotal(planned, target int) int {
+	before, delivered := 0, running(cmd.targets)
+	for _, app := range cmd.origSources {
+		before += app.countRequested
+	}
+	for _, app := range cmd.origTargets {
+		delivered -= app.countRunning
+	}
+
+	released := max(delivered, 0)
+	if target > 0 && target != before {
+		released = released * before / atLeastOne(target)
+	}
+	keep := min(max(planned, before-released), requested(cmd.sources))
+	if keep > planned {
+		cmd.say("Only %d new instances of %s are running, keeping %d instances of %s rather than %d\n",
+			max(delivered, 0), names(cmd.targets), keep, names(cmd.sources), planned)
+	}
+	return keep
+}
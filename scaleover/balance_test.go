@@ -0,0 +1,85 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/models"
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Balancing by readiness", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+
+	BeforeEach(func() {
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRequested: 4, countRunning: 4, state: "started", clock: newFakeClock()}},
+			targets: []*AppStatus{{name: "green", state: "stopped", weight: 1, clock: newFakeClock()}},
+			Clock:   newFakeClock(),
+		}
+		scaleoverCmdPlugin.origSources = snapshot(scaleoverCmdPlugin.sources)
+		scaleoverCmdPlugin.origTargets = snapshot(scaleoverCmdPlugin.targets)
+	})
+
+	It("keeps the planned count when every new instance is running", func() {
+		scaleoverCmdPlugin.targets[0].countRunning = 2
+		Ω(scaleoverCmdPlugin.readySourceTotal(2, 4)).Should(Equal(2))
+	})
+
+	It("only gives up as many instances as have come up", func() {
+		scaleoverCmdPlugin.targets[0].countRunning = 1
+		Ω(scaleoverCmdPlugin.readySourceTotal(2, 4)).Should(Equal(3))
+
+		scaleoverCmdPlugin.targets[0].countRunning = 0
+		Ω(scaleoverCmdPlugin.readySourceTotal(0, 4)).Should(Equal(4))
+	})
+
+	It("gives up instances in proportion when the targets end with fewer", func() {
+		scaleoverCmdPlugin.targets[0].countRunning = 1
+		Ω(scaleoverCmdPlugin.readySourceTotal(0, 2)).Should(Equal(2))
+	})
+
+	It("never scales the sources back up", func() {
+		scaleoverCmdPlugin.sources[0].countRequested = 2
+		Ω(scaleoverCmdPlugin.readySourceTotal(1, 4)).Should(Equal(2))
+	})
+
+	It("scales the source down only by the targets that start, carrying on past crashes", func() {
+		fakeCliConnection.GetAppStub = func(string) (plugin_models.GetAppModel, error) {
+			// every other new instance crashes
+			var app plugin_models.GetAppModel
+			for i := 0; i < scaleoverCmdPlugin.targets[0].countRequested; i++ {
+				state := "running"
+				if i%2 == 1 {
+					state = "crashed"
+				}
+				app.Instances = append(app.Instances, plugin_models.GetApp_AppInstanceFields{State: state})
+			}
+			return app, nil
+		}
+
+		opts := Options{BatchSize: 1, Balance: BalanceReady, StartTimeout: time.Minute}
+		Ω(scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, opts)).Should(Succeed())
+		Ω(scaleoverCmdPlugin.targets[0].countRequested).Should(Equal(4))
+		Ω(scaleoverCmdPlugin.targets[0].countRunning).Should(Equal(2))
+		Ω(scaleoverCmdPlugin.sources[0].countRequested).Should(Equal(2))
+		Ω(scaleoverCmdPlugin.sources[0].state).Should(Equal("started"))
+	})
+})
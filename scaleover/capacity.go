@@ -0,0 +1,213 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+//Amount is a number of instances, or a percentage of the source apps' requested instances
+type Amount struct {
+	N       int
+	Percent bool
+}
+
+//ParseAmount reads N or P%
+func ParseAmount(value string) (Amount, error) {
+	value = strings.TrimSpace(value)
+	amount := Amount{Percent: strings.HasSuffix(value, "%")}
+	n, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if err != nil || n < 0 {
+		return amount, fmt.Errorf("%q must be a whole number of instances or a percentage like 25%%", value)
+	}
+	amount.N = n
+	return amount, nil
+}
+
+func (a Amount) String() string {
+	if a.Percent {
+		return fmt.Sprintf("%d%%", a.N)
+	}
+	return strconv.Itoa(a.N)
+}
+
+//resolve is the number of instances the amount comes to, rounding percentages of total up
+func (a Amount) resolve(total int) int {
+	if a.Percent {
+		return (total*a.N + 99) / 100
+	}
+	return a.N
+}
+
+//MarshalJSON writes the amount as it was given, so state files resume with the same meaning
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+//UnmarshalJSON reads "P%", "N" or N
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		value = string(data)
+	}
+	amount, err := ParseAmount(value)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+//capacityLimits are --min-available and --max-surge in instances
+type capacityLimits struct {
+	minAvailable int // running instances across every app, 0 for no minimum
+	maxRequested int // requested instances across every app, 0 for no maximum
+}
+
+//capacityLimits resolves the options against the sources' requested instances before the scaleover
+func (cmd *Scaleover) capacityLimits(opts Options) capacityLimits {
+	var limits capacityLimits
+	base, targets := requested(cmd.sources), requestedStarted(cmd.targets)
+	if len(cmd.origSources) > 0 {
+		base, targets = 0, 0
+		for _, app := range cmd.origSources {
+			base += app.countRequested
+		}
+		for _, app := range cmd.origTargets {
+			if app.state != "stopped" {
+				targets += app.countRequested
+			}
+		}
+	}
+
+	if opts.MinAvailable != nil {
+		limits.minAvailable = opts.MinAvailable.resolve(base)
+	}
+	if opts.MaxSurge != nil {
+		limits.maxRequested = max(base, opts.TargetInstances) + targets + opts.MaxSurge.resolve(base)
+	}
+	return limits
+}
+
+//checkCapacityLimits makes sure the limits can be kept before anything is scaled
+func (cmd *Scaleover) checkCapacityLimits(rolloverTime time.Duration, opts Options) error {
+	limits := cmd.capacityLimits(opts)
+	if limits.minAvailable > requested(cmd.sources) {
+		return fmt.Errorf("--min-available %s needs %d instances running, but %s only has %d",
+			opts.MinAvailable, limits.minAvailable, names(cmd.sources), requested(cmd.sources))
+	}
+	_, err := limits.apply(requested(cmd.sources), requested(cmd.targets), newStrategy(opts).Plan(cmd.rollout(rolloverTime, opts)))
+	return err
+}
+
+//apply breaks up or reorders the steps so that none of them takes the running instances
+//below minAvailable or the requested instances above maxRequested. Steps normally start
+//the new instances before stopping the old ones. When that would surge too far they stop
+//first, and when neither keeps within the limits they're split into smaller moves.
+func (limits capacityLimits) apply(app1, app2 int, steps []Step) ([]Step, error) {
+	if limits.minAvailable == 0 && limits.maxRequested == 0 {
+		return steps, nil
+	}
+
+	var limited []Step
+	for _, step := range steps {
+		to1, to2 := min(step.App1, app1), max(step.App2, app2)
+		if to1+to2 < limits.minAvailable {
+			return nil, fmt.Errorf("The scaleover plans to go down to %d instances, below --min-available %d",
+				to1+to2, limits.minAvailable)
+		}
+
+		for app1 != to1 || app2 != to2 {
+			next := Step{App1: to1, App2: to2}
+			room, spare := limits.maxRequested-(app1+app2), app1+app2-limits.minAvailable
+			switch {
+			case limits.maxRequested == 0 || app1+to2 <= limits.maxRequested:
+			case to1+app2 >= limits.minAvailable && to1+to2 <= limits.maxRequested:
+				next.DownFirst = true
+			case room > 0:
+				up := min(room, to2-app2)
+				next = Step{App1: max(app1-up, to1), App2: app2 + up}
+			case spare > 0 && app1 > to1:
+				down := min(spare, app1-to1)
+				next = Step{App1: app1 - down, App2: min(app2+down, to2), DownFirst: true}
+			default:
+				return nil, fmt.Errorf("--min-available %d and --max-surge leave no room to move an instance with %d instances",
+					limits.minAvailable, app1+app2)
+			}
+			if next.App1 == to1 && next.App2 == to2 {
+				next.Wait = step.Wait
+			}
+			limited = append(limited, next)
+			app1, app2 = next.App1, next.App2
+		}
+	}
+	return limited, nil
+}
+
+//requestedStarted is the apps' requested instances, leaving out stopped apps, which keep
+//one instance requested while they're stopped
+func requestedStarted(apps []*AppStatus) int {
+	total := 0
+	for _, app := range apps {
+		if app.state != "stopped" {
+			total += app.countRequested
+		}
+	}
+	return total
+}
+
+//capacityError is a limit found broken between steps
+type capacityError struct {
+	message string
+}
+
+func (err *capacityError) Error() string {
+	return err.message
+}
+
+//verifyCapacity checks the limits against what the Cloud Controller says the apps have now.
+//Every instance it lists counts as requested, whatever its state, and stopped apps have none.
+func (cmd *Scaleover) verifyCapacity(cliConnection plugin.CliConnection, limits capacityLimits) error {
+	requested, running := 0, 0
+	for _, app := range cmd.apps() {
+		if app.state == "stopped" {
+			continue
+		}
+		states, err := app.instanceStates(cliConnection)
+		if err != nil {
+			return &capacityError{fmt.Sprintf("Can't check the instances of %s against --min-available and --max-surge: %s", app.name, err)}
+		}
+		requested += len(states)
+		for _, state := range states {
+			if strings.EqualFold(state, "running") {
+				running++
+			}
+		}
+	}
+
+	if limits.maxRequested > 0 && requested > limits.maxRequested {
+		return &capacityError{fmt.Sprintf("%d instances are requested, more than --max-surge allows (%d)", requested, limits.maxRequested)}
+	}
+	if running < limits.minAvailable {
+		return &capacityError{fmt.Sprintf("Only %d instances are running, fewer than --min-available %d", running, limits.minAvailable)}
+	}
+	return nil
+}
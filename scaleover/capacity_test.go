@@ -0,0 +1,166 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/models"
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Capacity limits", func() {
+	var scaleoverCmdPlugin *Scaleover
+
+	amount := func(value string) *Amount {
+		a, err := ParseAmount(value)
+		Ω(err).ShouldNot(HaveOccurred())
+		return &a
+	}
+
+	BeforeEach(func() {
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRequested: 4, countRunning: 4, state: "started"}},
+			targets: []*AppStatus{{name: "green", state: "stopped"}},
+		}
+	})
+
+	It("reads counts and percentages", func() {
+		Ω(*amount("3")).Should(Equal(Amount{N: 3}))
+		Ω(*amount("25%")).Should(Equal(Amount{N: 25, Percent: true}))
+		Ω(amount("25%").resolve(10)).Should(Equal(3))
+		Ω(amount("3").resolve(10)).Should(Equal(3))
+
+		_, err := ParseAmount("-1")
+		Ω(err).Should(MatchError(`"-1" must be a whole number of instances or a percentage like 25%`))
+		_, err = ParseAmount("lots")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("saves amounts as they were given", func() {
+		data, err := json.Marshal(Options{MinAvailable: amount("50%"), MaxSurge: amount("1")})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var opts Options
+		Ω(json.Unmarshal(data, &opts)).Should(Succeed())
+		Ω(*opts.MinAvailable).Should(Equal(Amount{N: 50, Percent: true}))
+		Ω(*opts.MaxSurge).Should(Equal(Amount{N: 1}))
+	})
+
+	It("resolves percentages against the sources' requested instances", func() {
+		limits := scaleoverCmdPlugin.capacityLimits(Options{MinAvailable: amount("75%"), MaxSurge: amount("25%")})
+		Ω(limits).Should(Equal(capacityLimits{minAvailable: 3, maxRequested: 5}))
+		Ω(scaleoverCmdPlugin.capacityLimits(Options{})).Should(Equal(capacityLimits{}))
+	})
+
+	It("stops old instances first when there's no room to surge", func() {
+		opts := Options{BatchSize: 2, MinAvailable: amount("2"), MaxSurge: amount("0")}
+		Ω(scaleoverCmdPlugin.plan(4*time.Minute, opts)).Should(Equal([]Step{
+			{App1: 2, App2: 2, Wait: time.Minute, DownFirst: true},
+			{App1: 0, App2: 4, DownFirst: true},
+		}))
+	})
+
+	It("splits steps that would break both limits", func() {
+		opts := Options{BatchSize: 2, MinAvailable: amount("4"), MaxSurge: amount("1")}
+		Ω(scaleoverCmdPlugin.plan(4*time.Minute, opts)).Should(Equal([]Step{
+			{App1: 3, App2: 1},
+			{App1: 2, App2: 2, Wait: time.Minute},
+			{App1: 1, App2: 3},
+			{App1: 0, App2: 4},
+		}))
+	})
+
+	It("refuses limits that can't be kept before starting", func() {
+		err := scaleoverCmdPlugin.checkCapacityLimits(time.Minute, Options{BatchSize: 1, MinAvailable: amount("4"), MaxSurge: amount("0")})
+		Ω(err).Should(MatchError("--min-available 4 and --max-surge leave no room to move an instance with 4 instances"))
+
+		err = scaleoverCmdPlugin.checkCapacityLimits(time.Minute, Options{BatchSize: 1, MinAvailable: amount("5")})
+		Ω(err).Should(MatchError("--min-available 5 needs 5 instances running, but blue only has 4"))
+
+		Ω(scaleoverCmdPlugin.checkCapacityLimits(time.Minute, Options{BatchSize: 1, MinAvailable: amount("100%"), MaxSurge: amount("1")})).Should(Succeed())
+	})
+
+	It("prints the limits and the order of each step in the plan", func() {
+		out := &bytes.Buffer{}
+		run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 4, Strategy: StrategyLinear, MinAvailable: amount("0"), MaxSurge: amount("0")})
+		scaleoverCmdPlugin.printPlan(out, run)
+
+		Ω(out.String()).Should(Equal(`Scaleover plan for blue to green using the linear strategy, 1 steps:
+Steps keep at most 4 instances requested.
+
+Step 1 at +0s
+  cf stop blue
+  cf scale -i 1 blue
+  cf scale -i 4 green
+  cf start green
+  blue (stopped) 1 instances, green (started) 4 instances
+`))
+	})
+
+	Describe("between steps", func() {
+		var fakeCliConnection *pluginfakes.FakeCliConnection
+
+		BeforeEach(func() {
+			fakeCliConnection = &pluginfakes.FakeCliConnection{}
+			scaleoverCmdPlugin.sources[0].countRequested = 2
+			scaleoverCmdPlugin.targets[0].countRequested = 2
+			scaleoverCmdPlugin.targets[0].state = "started"
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{Instances: []plugin_models.GetApp_AppInstanceFields{
+				{State: "running"}, {State: "starting"},
+			}}, nil)
+		})
+
+		It("checks the instances that are actually running", func() {
+			Ω(scaleoverCmdPlugin.verifyCapacity(fakeCliConnection, capacityLimits{minAvailable: 2})).Should(Succeed())
+			err := scaleoverCmdPlugin.verifyCapacity(fakeCliConnection, capacityLimits{minAvailable: 3})
+			Ω(err).Should(MatchError("Only 2 instances are running, fewer than --min-available 3"))
+		})
+
+		It("checks the instances the Cloud Controller lists rather than its own counts", func() {
+			scaleoverCmdPlugin.sources[0].countRequested = 1
+			err := scaleoverCmdPlugin.verifyCapacity(fakeCliConnection, capacityLimits{maxRequested: 3})
+			Ω(err).Should(MatchError("4 instances are requested, more than --max-surge allows (3)"))
+			Ω(fakeCliConnection.GetAppCallCount()).Should(Equal(2))
+		})
+
+		It("fails when it can't read the instances", func() {
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{}, errors.New("timeout"))
+			err := scaleoverCmdPlugin.verifyCapacity(fakeCliConnection, capacityLimits{maxRequested: 3})
+			Ω(err).Should(MatchError("Can't check the instances of blue against --min-available and --max-surge: timeout"))
+		})
+
+		It("doesn't count the instance a stopped app keeps", func() {
+			scaleoverCmdPlugin.sources[0].state = "stopped"
+			scaleoverCmdPlugin.sources[0].countRequested = 1
+			Ω(scaleoverCmdPlugin.verifyCapacity(fakeCliConnection, capacityLimits{maxRequested: 2})).Should(Succeed())
+		})
+
+		It("aborts the scaleover when a limit is broken", func() {
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{}, nil)
+			scaleoverCmdPlugin.sources[0].countRequested = 4
+			scaleoverCmdPlugin.targets[0] = &AppStatus{name: "green", state: "stopped"}
+
+			err := scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1, MinAvailable: amount("1")})
+			Ω(err).Should(MatchError("Only 0 instances are running, fewer than --min-available 1"))
+			Ω(scaleoverCmdPlugin.sources[0].countRequested).Should(Equal(3))
+		})
+	})
+})
@@ -0,0 +1,134 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/models"
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+//fakeClock moves forward only when the scaleover sleeps or waits, and remembers each wait
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Sleep(d)
+	fired := make(chan time.Time, 1)
+	fired <- c.Now()
+	return fired
+}
+
+func (c *fakeClock) elapsed() time.Duration {
+	return c.Now().Sub(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+var _ = Describe("Clock", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var clock *fakeClock
+
+	BeforeEach(func() {
+		clock = newFakeClock()
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRequested: 20, countRunning: 20, state: "started", clock: clock}},
+			targets: []*AppStatus{{name: "green", state: "stopped", weight: 1, clock: clock}},
+			Clock:   clock,
+		}
+	})
+
+	It("spreads 20 instances over an hour without sleeping through it", func() {
+		started := time.Now()
+		Ω(scaleoverCmdPlugin.doScaleover(fakeCliConnection, time.Hour, Options{BatchSize: 1})).Should(Succeed())
+
+		Ω(time.Since(started)).Should(BeNumerically("<", time.Second))
+		Ω(clock.waits).Should(HaveLen(19))
+		for _, wait := range clock.waits {
+			Ω(wait).Should(Equal(3 * time.Minute))
+		}
+		Ω(clock.elapsed()).Should(Equal(57 * time.Minute))
+		Ω(scaleoverCmdPlugin.targets[0].countRequested).Should(Equal(20))
+	})
+
+	It("waits for the canary for the whole duration", func() {
+		Ω(scaleoverCmdPlugin.doScaleover(fakeCliConnection, time.Hour, Options{BatchSize: 2, Strategy: StrategyCanary})).Should(Succeed())
+		Ω(clock.waits).Should(Equal([]time.Duration{time.Hour}))
+	})
+
+	It("polls the starting instances once a second and sleeps after they start", func() {
+		polls := 0
+		fakeCliConnection.GetAppStub = func(string) (plugin_models.GetAppModel, error) {
+			polls++
+			state := "starting"
+			if polls > 3 {
+				state = "running"
+			}
+			instances := make([]plugin_models.GetApp_AppInstanceFields, 20)
+			for i := range instances {
+				instances[i].State = state
+			}
+			return plugin_models.GetAppModel{Instances: instances}, nil
+		}
+
+		opts := Options{BatchSize: 20, WaitForStarted: true, PostStartSleep: 30 * time.Second}
+		Ω(scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, opts)).Should(Succeed())
+		Ω(clock.waits).Should(Equal([]time.Duration{time.Second, time.Second, time.Second, 30 * time.Second}))
+	})
+
+	It("times out waiting for instances by the clock", func() {
+		fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{
+			Instances: []plugin_models.GetApp_AppInstanceFields{{State: "starting"}},
+		}, nil)
+
+		err := scaleoverCmdPlugin.targets[0].waitForStart(fakeCliConnection, time.Minute)
+		Ω(err).Should(MatchError("Timed out after 1m0s waiting for green to start, 0 of 1 instances are running"))
+		Ω(clock.elapsed()).Should(BeNumerically("~", time.Minute, time.Second))
+	})
+
+	It("backs off between retries by the clock", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("cf failed"))
+		app := scaleoverCmdPlugin.targets[0]
+		app.retry = RetryPolicy{Retries: 2, Backoff: 10 * time.Second}
+
+		Ω(app.scaleUp(fakeCliConnection, 1)).ShouldNot(Succeed())
+		Ω(clock.waits).Should(Equal([]time.Duration{10 * time.Second, 20 * time.Second}))
+	})
+})
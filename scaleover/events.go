@@ -0,0 +1,159 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+//Values accepted by --output
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+//Names of the events written by --output json and --log-file
+const (
+	EventPlan        = "plan"
+	EventScale       = "scale"
+	EventRetry       = "retry"
+	EventRoute       = "route"
+	EventRunning     = "running"
+	EventHealth      = "health"
+	EventStartFailed = "start_failed"
+	EventStep        = "step"
+	EventRollback    = "rollback"
+	EventFinished    = "finished"
+	EventFailed      = "failed"
+)
+
+//Event is one line of JSON output, also handed to OnEvent
+type Event struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	App      *AppEvent `json:"app,omitempty"`
+	App1     *AppEvent `json:"app1,omitempty"`
+	App2     *AppEvent `json:"app2,omitempty"`
+	Command  []string  `json:"command,omitempty"`
+	Strategy string    `json:"strategy,omitempty"`
+	Plan     []Step    `json:"plan,omitempty"`
+	Step     int       `json:"step,omitempty"`
+	Steps    int       `json:"steps,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Passed   *bool     `json:"passed,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+//AppEvent is an app's counts and state at the time of an event
+type AppEvent struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	Requested int    `json:"requested"`
+	Running   int    `json:"running"`
+}
+
+func newAppEvent(app *AppStatus) *AppEvent {
+	if app == nil {
+		return nil
+	}
+	return &AppEvent{
+		Name:      app.name,
+		State:     app.state,
+		Requested: app.countRequested,
+		Running:   app.countRunning,
+	}
+}
+
+//newGroupEvent sums up the sources or the targets as if they were one app
+func newGroupEvent(apps []*AppStatus) *AppEvent {
+	if len(apps) == 0 {
+		return nil
+	}
+	return &AppEvent{
+		Name:      names(apps),
+		State:     state(apps),
+		Requested: requested(apps),
+		Running:   running(apps),
+	}
+}
+
+//eventLog writes each event as a line of JSON to Out and/or the log file, and hands it to OnEvent.
+//A nil eventLog discards everything, so callers don't need to check.
+type eventLog struct {
+	writers []io.Writer
+	out     bool // Out belongs to the events
+	clock   Clock
+	onEvent func(Event)
+	file    *os.File // the --log-file, closed by Close
+}
+
+func newEventLog(opts Options, clock Clock, onEvent func(Event), out io.Writer) (*eventLog, error) {
+	if opts.Output != OutputJSON && opts.LogFile == "" && onEvent == nil {
+		return nil, nil
+	}
+
+	log := &eventLog{clock: orSystemClock(clock), onEvent: onEvent}
+	if opts.Output == OutputJSON {
+		if out != nil {
+			log.writers = append(log.writers, out)
+		}
+		log.out = true
+	}
+	if opts.LogFile != "" {
+		file, err := os.OpenFile(opts.LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		log.writers = append(log.writers, file)
+		log.file = file
+	}
+	return log, nil
+}
+
+//Close closes the --log-file, if there is one
+func (log *eventLog) Close() error {
+	if log == nil || log.file == nil {
+		return nil
+	}
+	return log.file.Close()
+}
+
+func (log *eventLog) emit(e Event) {
+	if log == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = orSystemClock(log.clock).Now().UTC()
+	}
+	for _, w := range log.writers {
+		json.NewEncoder(w).Encode(e)
+	}
+	if log.onEvent != nil {
+		log.onEvent(e)
+	}
+}
+
+//toOut is true when Out belongs to the JSON events
+func (log *eventLog) toOut() bool {
+	return log != nil && log.out
+}
+
+//ValidOutput is true for the values --output accepts
+func ValidOutput(output string) bool {
+	return output == OutputText || output == OutputJSON
+}
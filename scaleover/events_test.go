@@ -0,0 +1,153 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Events", func() {
+	var dir string
+	var logFile string
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+
+	readEvents := func() []Event {
+		file, err := os.Open(logFile)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer file.Close()
+
+		var events []Event
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var e Event
+			Ω(json.Unmarshal(scanner.Bytes(), &e)).Should(Succeed())
+			events = append(events, e)
+		}
+		return events
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "scaleover-events")
+		Ω(err).ShouldNot(HaveOccurred())
+		logFile = filepath.Join(dir, "events.log")
+
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		events, err := newEventLog(Options{Output: OutputText, LogFile: logFile}, nil, nil, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRunning: 2, countRequested: 2, state: "started", events: events}},
+			targets: []*AppStatus{{name: "green", state: "stopped", events: events}},
+			events:  events,
+		}
+	})
+
+	AfterEach(func() {
+		scaleoverCmdPlugin.events.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("closes the --log-file", func() {
+		Ω(scaleoverCmdPlugin.events.Close()).Should(Succeed())
+		Ω(scaleoverCmdPlugin.events.file.Close()).ShouldNot(Succeed())
+
+		var discarded *eventLog
+		Ω(discarded.Close()).Should(Succeed())
+	})
+
+	It("discards events when neither --output json nor --log-file is given", func() {
+		events, err := newEventLog(Options{Output: OutputText}, nil, nil, &bytes.Buffer{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(events).Should(BeNil())
+		Ω(events.toOut()).Should(BeFalse())
+		events.emit(Event{Event: EventStep})
+	})
+
+	It("claims Out for --output json", func() {
+		out := &bytes.Buffer{}
+		events, err := newEventLog(Options{Output: OutputJSON}, nil, nil, out)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(events.toOut()).Should(BeTrue())
+
+		events.emit(Event{Event: EventStep, Step: 1})
+		Ω(out.String()).Should(ContainSubstring(`"event":"step","step":1`))
+	})
+
+	It("writes a line per cf command, step and the finish", func() {
+		err := scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var names []string
+		for _, e := range readEvents() {
+			names = append(names, e.Event)
+		}
+		Ω(names).Should(Equal([]string{
+			EventScale, EventScale, EventScale, EventStep,
+			EventScale, EventScale, EventScale, EventStep,
+		}))
+	})
+
+	It("records the command, the app and the step", func() {
+		scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1})
+		events := readEvents()
+
+		Ω(events[0].Command).Should(Equal([]string{"scale", "-i", "1", "green"}))
+		Ω(events[0].App.Name).Should(Equal("green"))
+		Ω(events[0].App.Requested).Should(Equal(1))
+		Ω(events[0].Time.IsZero()).Should(BeFalse())
+
+		Ω(events[3].Step).Should(Equal(1))
+		Ω(events[3].Steps).Should(Equal(2))
+		Ω(*events[3].App1).Should(Equal(AppEvent{Name: "blue", State: "started", Requested: 1, Running: 2}))
+		Ω(events[3].App2.Requested).Should(Equal(1))
+	})
+
+	It("records a rollback", func() {
+		scaleoverCmdPlugin.origSources = snapshot(scaleoverCmdPlugin.sources)
+		scaleoverCmdPlugin.origTargets = snapshot(scaleoverCmdPlugin.targets)
+		scaleoverCmdPlugin.targets[0].scaleUp(fakeCliConnection, 1)
+		scaleoverCmdPlugin.rollback(fakeCliConnection)
+
+		events := readEvents()
+		last := events[len(events)-1]
+		Ω(last.Event).Should(Equal(EventRollback))
+		Ω(last.App2.State).Should(Equal("stopped"))
+	})
+
+	It("appends to an existing log file", func() {
+		Ω(ioutil.WriteFile(logFile, []byte("{\"event\":\"finished\"}\n"), 0644)).Should(Succeed())
+		scaleoverCmdPlugin.events.emit(Event{Event: EventFailed, Error: "boom"})
+
+		events := readEvents()
+		Ω(events).Should(HaveLen(2))
+		Ω(events[1].Error).Should(Equal("boom"))
+	})
+
+	It("records whether the health check passed", func() {
+		e := healthEvent(HealthCheck{URL: "http://green.example.com"}, nil)
+		Ω(*e.Passed).Should(BeTrue())
+		Ω(e.Error).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,118 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//HealthCheck polls a URL until it answers correctly enough times in a row
+type HealthCheck struct {
+	URL       string        `json:"url"`
+	Status    int           `json:"status"`
+	Body      string        `json:"body"`
+	BodyRegex string        `json:"bodyRegex"`
+	Successes int           `json:"successes"`
+	Timeout   time.Duration `json:"timeout"`
+	Interval  time.Duration `json:"interval"`
+}
+
+//healthRequestTimeout bounds a single request so one hung connection can't eat the whole timeout
+const healthRequestTimeout = 10 * time.Second
+
+func newHealthCheck() HealthCheck {
+	return HealthCheck{
+		Status:    http.StatusOK,
+		Successes: 1,
+		Timeout:   2 * time.Minute,
+		Interval:  1 * time.Second,
+	}
+}
+
+//wait polls until Successes consecutive probes pass or Timeout runs out
+func (check HealthCheck) wait(clock Clock) error {
+	client := &http.Client{Timeout: healthRequestTimeout}
+	deadline := clock.Now().Add(check.Timeout)
+	passed := 0
+
+	for {
+		err := check.probe(client)
+		if err == nil {
+			passed++
+			if passed >= check.Successes {
+				return nil
+			}
+		} else {
+			passed = 0
+		}
+
+		if clock.Now().After(deadline) {
+			if err == nil {
+				err = fmt.Errorf("only %d of %d checks passed in a row", passed, check.Successes)
+			}
+			return fmt.Errorf("Health check of %s failed after %s: %s", check.URL, check.Timeout, err)
+		}
+		clock.Sleep(check.Interval)
+	}
+}
+
+//probe makes one request and checks the status and body
+func (check HealthCheck) probe(client *http.Client) error {
+	resp, err := client.Get(check.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != check.Status {
+		return fmt.Errorf("expected status %d, got %d", check.Status, resp.StatusCode)
+	}
+
+	if check.Body == "" && check.BodyRegex == "" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if check.Body != "" && !strings.Contains(string(body), check.Body) {
+		return fmt.Errorf("response did not contain %q", check.Body)
+	}
+	if check.BodyRegex != "" {
+		re, err := regexp.Compile(check.BodyRegex)
+		if err != nil {
+			return err
+		}
+		if !re.Match(body) {
+			return fmt.Errorf("response did not match %q", check.BodyRegex)
+		}
+	}
+	return nil
+}
+
+func healthEvent(check HealthCheck, err error) Event {
+	passed := err == nil
+	e := Event{Event: EventHealth, URL: check.URL, Passed: &passed}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
@@ -0,0 +1,113 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health check", func() {
+	var server *httptest.Server
+	var requests int32
+	var check HealthCheck
+
+	// serve answers with status and body for each request in turn, repeating the last one
+	serve := func(statuses []int, bodies []string) {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := int(atomic.AddInt32(&requests, 1)) - 1
+			if n >= len(statuses) {
+				n = len(statuses) - 1
+			}
+			w.WriteHeader(statuses[n])
+			fmt.Fprint(w, bodies[n])
+		}))
+		check.URL = server.URL
+	}
+
+	BeforeEach(func() {
+		requests = 0
+		check = newHealthCheck()
+		check.Interval = time.Millisecond
+		check.Timeout = 200 * time.Millisecond
+	})
+
+	AfterEach(func() {
+		if server != nil {
+			server.Close()
+		}
+	})
+
+	It("passes on the first good answer by default", func() {
+		serve([]int{200}, []string{"ok"})
+		Expect(check.wait(systemClock{})).To(Succeed())
+		Expect(requests).To(BeEquivalentTo(1))
+	})
+
+	It("keeps polling until the app answers", func() {
+		serve([]int{503, 503, 200}, []string{"", "", "ok"})
+		Expect(check.wait(systemClock{})).To(Succeed())
+		Expect(requests).To(BeEquivalentTo(3))
+	})
+
+	It("needs the configured number of successes in a row", func() {
+		check.Successes = 3
+		serve([]int{200, 500, 200, 200, 200}, []string{"", "", "", "", ""})
+		Expect(check.wait(systemClock{})).To(Succeed())
+		Expect(requests).To(BeEquivalentTo(5))
+	})
+
+	It("checks for the expected status code", func() {
+		check.Status = http.StatusNoContent
+		serve([]int{200}, []string{""})
+		Expect(check.wait(systemClock{})).To(MatchError(ContainSubstring("expected status 204, got 200")))
+	})
+
+	It("checks the body contains the expected text", func() {
+		check.Body = `"status":"UP"`
+		serve([]int{200, 200}, []string{`{"status":"DOWN"}`, `{"status":"UP"}`})
+		Expect(check.wait(systemClock{})).To(Succeed())
+		Expect(requests).To(BeEquivalentTo(2))
+	})
+
+	It("checks the body matches the expected pattern", func() {
+		check.BodyRegex = `^version: 1\.1\.\d+$`
+		serve([]int{200}, []string{"version: 1.0.9"})
+		err := check.wait(systemClock{})
+		Expect(err).To(MatchError(ContainSubstring(`response did not match "^version: 1\\.1\\.\\d+$"`)))
+		Expect(err).To(MatchError(ContainSubstring("failed after 200ms")))
+	})
+
+	It("gates scaling the source app down", func() {
+		serve([]int{500}, []string{""})
+		fakeCliConnection := &pluginfakes.FakeCliConnection{}
+		scaleoverCmdPlugin := &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRequested: 2, countRunning: 2, state: "started"}},
+			targets: []*AppStatus{{name: "green", state: "stopped"}},
+		}
+
+		run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 1, HealthCheck: check})
+		Expect(scaleoverCmdPlugin.continueScaleover(fakeCliConnection, run)).NotTo(Succeed())
+		Expect(scaleoverCmdPlugin.sources[0].countRequested).To(Equal(2))
+		Expect(scaleoverCmdPlugin.targets[0].countRequested).To(Equal(1))
+	})
+})
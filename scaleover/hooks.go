@@ -0,0 +1,60 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+//Hooks are shell commands run as the scaleover starts, finishes or fails. Each is
+//run with sh -c, with SCALEOVER_HOOK, SCALEOVER_APP1 and SCALEOVER_APP2 set.
+type Hooks struct {
+	Before    []string `json:"before,omitempty"`
+	After     []string `json:"after,omitempty"`
+	OnFailure []string `json:"onFailure,omitempty"`
+}
+
+const (
+	hookBefore    = "before"
+	hookAfter     = "after"
+	hookOnFailure = "on-failure"
+)
+
+//runHooks runs each command in turn, stopping at the first that fails
+func (cmd *Scaleover) runHooks(stage string, commands []string) error {
+	env := append(os.Environ(),
+		"SCALEOVER_HOOK="+stage,
+		"SCALEOVER_APP1="+names(cmd.sources),
+		"SCALEOVER_APP2="+names(cmd.targets))
+
+	// Out belongs to the events with --output json
+	out := cmd.out()
+	if cmd.events.toOut() {
+		out = os.Stderr
+	}
+
+	for _, command := range commands {
+		cmd.say("Running %s hook: %s\n", stage, command)
+		hook := exec.Command("sh", "-c", command)
+		hook.Env, hook.Stdout, hook.Stderr = env, out, os.Stderr
+		if err := hook.Run(); err != nil {
+			return fmt.Errorf("The %s hook `%s` failed: %s", stage, strings.TrimSpace(command), err)
+		}
+	}
+	return nil
+}
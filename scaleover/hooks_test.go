@@ -0,0 +1,62 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hooks", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "scaleover-hooks")
+		Ω(err).ShouldNot(HaveOccurred())
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue"}, {name: "red"}},
+			targets: []*AppStatus{{name: "green"}},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("runs each command with the apps in its environment", func() {
+		out := filepath.Join(dir, "hook.out")
+		Ω(scaleoverCmdPlugin.runHooks(hookBefore, []string{
+			`echo "$SCALEOVER_HOOK $SCALEOVER_APP1 $SCALEOVER_APP2" > ` + out,
+			"echo again >> " + out,
+		})).Should(Succeed())
+
+		written, err := ioutil.ReadFile(out)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(written)).Should(Equal("before blue,red green\nagain\n"))
+	})
+
+	It("stops at the first command that fails", func() {
+		out := filepath.Join(dir, "hook.out")
+		err := scaleoverCmdPlugin.runHooks(hookAfter, []string{"exit 3", "touch " + out})
+		Ω(err).Should(MatchError("The after hook `exit 3` failed: exit status 3"))
+		Ω(out).ShouldNot(BeAnExistingFile())
+	})
+})
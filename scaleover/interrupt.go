@@ -0,0 +1,84 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import "time"
+
+//What to do when a scaleover is interrupted
+const (
+	InterruptContinue = "continue"
+	InterruptHold     = "hold"
+	InterruptAbort    = "abort"
+	InterruptRollback = "rollback"
+)
+
+//interruptedError stops the scaleover with the action that was chosen
+type interruptedError struct {
+	action string
+}
+
+func (err *interruptedError) Error() string {
+	switch err.action {
+	case InterruptHold:
+		return "Scaleover interrupted, holding here. Continue it with `cf scaleover --resume`"
+	case InterruptRollback:
+		return "Scaleover interrupted, rolling back"
+	}
+	return "Scaleover interrupted, leaving both apps as they are"
+}
+
+//ValidInterruptPolicy is true for the actions that can be picked without a terminal
+func ValidInterruptPolicy(policy string) bool {
+	return policy == InterruptHold || policy == InterruptRollback
+}
+
+//checkInterrupt handles an interrupt that arrived while the last step was running
+func (cmd *Scaleover) checkInterrupt(run *runState) error {
+	select {
+	case <-cmd.Interrupts:
+		return cmd.handleInterrupt(run)
+	default:
+		return nil
+	}
+}
+
+//pause waits out the interval between steps, unless interrupted
+func (cmd *Scaleover) pause(run *runState, wait time.Duration) error {
+	done := orSystemClock(cmd.Clock).After(wait)
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-cmd.Interrupts:
+			if err := cmd.handleInterrupt(run); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+//handleInterrupt follows the --on-interrupt policy, unless OnInterrupt chooses something else
+func (cmd *Scaleover) handleInterrupt(run *runState) error {
+	action := run.Options.OnInterrupt
+	if cmd.OnInterrupt != nil {
+		action = cmd.OnInterrupt(action)
+	}
+
+	if action == InterruptContinue {
+		return nil
+	}
+	return &interruptedError{action: action}
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Interrupts", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var interrupts chan os.Signal
+
+	BeforeEach(func() {
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		interrupts = make(chan os.Signal, 1)
+		scaleoverCmdPlugin = &Scaleover{
+			Interrupts: interrupts,
+			sources:    []*AppStatus{{name: "blue", countRequested: 10, countRunning: 10, state: "started"}},
+			targets:    []*AppStatus{{name: "green", countRequested: 0, state: "stopped"}},
+		}
+	})
+
+	Describe("between steps", func() {
+		It("follows the --on-interrupt policy without a terminal", func() {
+			interrupts <- os.Interrupt
+			run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 1, OnInterrupt: InterruptRollback})
+
+			err := scaleoverCmdPlugin.continueScaleover(fakeCliConnection, run)
+			Expect(err).To(Equal(&interruptedError{action: InterruptRollback}))
+			Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(0))
+		})
+
+		It("stops waiting for the next step when interrupted", func() {
+			interrupts <- os.Interrupt
+			run := &runState{Options: Options{OnInterrupt: InterruptHold}}
+
+			Expect(scaleoverCmdPlugin.pause(run, time.Hour)).To(MatchError(ContainSubstring("cf scaleover --resume")))
+		})
+
+		It("lets OnInterrupt choose what to do", func() {
+			var policy string
+			scaleoverCmdPlugin.OnInterrupt = func(p string) string {
+				policy = p
+				return InterruptContinue
+			}
+			interrupts <- os.Interrupt
+			run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 5, OnInterrupt: InterruptRollback})
+
+			Expect(scaleoverCmdPlugin.continueScaleover(fakeCliConnection, run)).To(Succeed())
+			Expect(policy).To(Equal(InterruptRollback))
+			Expect(scaleoverCmdPlugin.targets[0].countRequested).To(Equal(10))
+		})
+
+		It("runs every step when nothing interrupts it", func() {
+			run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 5, OnInterrupt: InterruptHold})
+			Expect(scaleoverCmdPlugin.continueScaleover(fakeCliConnection, run)).To(Succeed())
+			Expect(scaleoverCmdPlugin.targets[0].countRequested).To(Equal(10))
+		})
+	})
+})
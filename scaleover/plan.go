@@ -0,0 +1,98 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+//printPlan writes out every step of the run without touching any of the apps
+func (cmd *Scaleover) printPlan(out io.Writer, run *runState) {
+	sources, targets := copyApps(cmd.sources), copyApps(cmd.targets)
+
+	fmt.Fprintf(out, "Scaleover plan for %s to %s using the %s strategy, %d steps:\n",
+		names(sources), names(targets), run.Options.Strategy, len(run.Steps)-run.Step)
+	if run.Options.WaitForStarted || run.Options.HealthCheck.URL != "" {
+		fmt.Fprintln(out, "Times assume new instances start immediately, waiting for them will push later steps back.")
+	}
+
+	if run.Options.BatchSizePercent > 0 {
+		fmt.Fprintf(out, "--batch-size %d%% moves %d instances at a time.\n", run.Options.BatchSizePercent, run.Options.BatchSize)
+	}
+	if run.Options.LeavePercent > 0 {
+		fmt.Fprintf(out, "--leave %d%% leaves %d instances of %s.\n", run.Options.LeavePercent, run.Options.Leave, names(sources))
+	}
+
+	limits := cmd.capacityLimits(run.Options)
+	if limits.minAvailable > 0 {
+		fmt.Fprintf(out, "Steps keep at least %d instances running.\n", limits.minAvailable)
+	}
+	if limits.maxRequested > 0 {
+		fmt.Fprintf(out, "Steps keep at most %d instances requested.\n", limits.maxRequested)
+	}
+
+	var at time.Duration
+	for i := run.Step; i < len(run.Steps); i++ {
+		next := run.Steps[i]
+		fmt.Fprintf(out, "\nStep %d at +%s\n", i+1, at)
+		if i == run.Step && cmd.route != nil {
+			for _, app := range targets {
+				printCommands(out, app.mapRouteCommands(*cmd.route))
+			}
+		}
+		if next.DownFirst {
+			printScaleDown(out, cmd.sourceCounts(next.App1), sources)
+		}
+		for j, count := range cmd.targetCounts(next.App2) {
+			if targets[j].needsScaleUp(count) {
+				printCommands(out, targets[j].scaleUpCommands(count))
+			}
+		}
+		if run.Options.WaitForStarted {
+			fmt.Fprintf(out, "  wait for all %s instances to be running\n", names(targets))
+		}
+		if run.Options.HealthCheck.URL != "" {
+			fmt.Fprintf(out, "  wait for %s to answer %d\n", run.Options.HealthCheck.URL, run.Options.HealthCheck.Status)
+		}
+		if !next.DownFirst {
+			printScaleDown(out, cmd.sourceCounts(next.App1), sources)
+		}
+		if cmd.unmapsOld(run, sources) {
+			for _, app := range sources {
+				printCommands(out, app.unmapRouteCommands(*cmd.route))
+			}
+		}
+		fmt.Fprintf(out, "  %s\n", statusLine(sources, targets))
+		at += next.Wait
+	}
+}
+
+//printScaleDown writes the commands that take each source down to its count
+func printScaleDown(out io.Writer, counts []int, sources []*AppStatus) {
+	for j, count := range counts {
+		if sources[j].needsScaleDown(count) {
+			printCommands(out, sources[j].scaleDownCommands(count))
+		}
+	}
+}
+
+func printCommands(out io.Writer, commands [][]string) {
+	for _, args := range commands {
+		fmt.Fprintf(out, "  cf %s\n", strings.Join(args, " "))
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dry run", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRequested: 4, countRunning: 4, state: "started"}},
+			targets: []*AppStatus{{name: "green", state: "stopped"}},
+		}
+	})
+
+	It("prints every command and the resulting counts", func() {
+		run := scaleoverCmdPlugin.newRunState(4*time.Minute, Options{BatchSize: 2, Strategy: StrategyLinear})
+		scaleoverCmdPlugin.printPlan(out, run)
+
+		Expect(out.String()).To(Equal(`Scaleover plan for blue to green using the linear strategy, 2 steps:
+
+Step 1 at +0s
+  cf scale -i 2 green
+  cf start green
+  cf scale -i 2 blue
+  blue (started) 2 instances, green (started) 2 instances
+
+Step 2 at +1m0s
+  cf scale -i 4 green
+  cf stop blue
+  cf scale -i 1 blue
+  blue (stopped) 1 instances, green (started) 4 instances
+`))
+	})
+
+	It("notes where it will wait for the new instances", func() {
+		check := newHealthCheck()
+		check.URL = "http://green.example.com"
+		run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 4, WaitForStarted: true, HealthCheck: check})
+		scaleoverCmdPlugin.printPlan(out, run)
+
+		Expect(out.String()).To(ContainSubstring("Times assume new instances start immediately"))
+		Expect(out.String()).To(ContainSubstring("  wait for all green instances to be running\n  wait for http://green.example.com to answer 200\n"))
+	})
+
+	It("shows what percentages come to", func() {
+		opts := resolvePercentages(Options{BatchSizePercent: 30, LeavePercent: 10, Strategy: StrategyLinear}, 4)
+		Expect(opts.BatchSize).To(Equal(2))
+		Expect(opts.Leave).To(Equal(1))
+
+		scaleoverCmdPlugin.printPlan(out, scaleoverCmdPlugin.newRunState(0, opts))
+		Expect(out.String()).To(ContainSubstring("--batch-size 30% moves 2 instances at a time.\n"))
+		Expect(out.String()).To(ContainSubstring("--leave 10% leaves 1 instances of blue.\n"))
+	})
+
+	It("leaves the apps as they were", func() {
+		run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 1})
+		scaleoverCmdPlugin.printPlan(out, run)
+
+		Expect(scaleoverCmdPlugin.sources[0].countRequested).To(Equal(4))
+		Expect(scaleoverCmdPlugin.targets[0].state).To(Equal("stopped"))
+	})
+})
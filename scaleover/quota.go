@@ -0,0 +1,186 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+//headroom is how much memory, in MB, can still be used before a quota is hit
+type headroom struct {
+	free  int64
+	quota string // the quota that leaves the least free, empty when neither org nor space is limited
+}
+
+//checkQuota makes sure the extra instances the scaleover starts fit in the org and space
+//quotas, reducing the batch size until they do. It errors when not even one at a time fits.
+func (cmd *Scaleover) checkQuota(cliConnection plugin.CliConnection, rolloverTime time.Duration,
+	opts Options) (Options, error) {
+	if cmd.qualified() {
+		cmd.say("Skipping the quota check: it only knows about the targeted space\n")
+		return opts, nil
+	}
+	room, err := memoryHeadroom(cliConnection)
+	if err != nil {
+		cmd.say("Skipping the quota check: %s\n", err)
+		return opts, nil
+	}
+	if room.quota == "" {
+		return opts, nil
+	}
+
+	need := cmd.extraMemory(cmd.plan(rolloverTime, opts))
+	if need <= room.free {
+		return opts, nil
+	}
+	if opts.Strategy == StrategySteps {
+		return opts, fmt.Errorf("The scaleover needs up to %dM more memory than %s and %s use now, but the %s only has %dM free",
+			need, names(cmd.sources), names(cmd.targets), room.quota, room.free)
+	}
+
+	reduced := opts
+	for reduced.BatchSize = atLeastOne(opts.BatchSize) - 1; reduced.BatchSize >= 1; reduced.BatchSize-- {
+		if cmd.extraMemory(cmd.plan(rolloverTime, reduced)) <= room.free {
+			cmd.say("Reducing --batch-size from %d to %d: moving %d at a time needs up to %dM more memory, but the %s only has %dM free\n",
+				opts.BatchSize, reduced.BatchSize, opts.BatchSize, need, room.quota, room.free)
+			return reduced, nil
+		}
+	}
+	smallest := cmd.targets[0]
+	for _, app := range cmd.targets {
+		if app.memory < smallest.memory {
+			smallest = app
+		}
+	}
+	return opts, fmt.Errorf("The %s only has %dM free, not enough to start even one more %dM instance of %s",
+		room.quota, room.free, smallest.memory, smallest.name)
+}
+
+//extraMemory is the most memory, in MB, the steps need on top of what the apps use now.
+//Each step starts the new target instances before the sources are scaled down, so that is when the peak comes.
+func (cmd *Scaleover) extraMemory(steps []Step) int64 {
+	sources, targets := counts(cmd.sources), counts(cmd.targets)
+	now := memoryFor(cmd.sources, sources) + memoryFor(cmd.targets, targets)
+
+	var peak int64
+	for _, step := range steps {
+		if step.DownFirst {
+			for i, n := range cmd.sourceCounts(step.App1) {
+				sources[i] = min(sources[i], n)
+			}
+		}
+		for i, n := range cmd.targetCounts(step.App2) {
+			targets[i] = max(targets[i], n)
+		}
+		if extra := memoryFor(cmd.sources, sources) + memoryFor(cmd.targets, targets) - now; extra > peak {
+			peak = extra
+		}
+		for i, n := range cmd.sourceCounts(step.App1) {
+			sources[i] = min(sources[i], n)
+		}
+	}
+	return peak
+}
+
+//memoryFor is how much memory, in MB, the apps use with counts[i] instances of apps[i]
+func memoryFor(apps []*AppStatus, counts []int) int64 {
+	var used int64
+	for i, app := range apps {
+		used += int64(counts[i]) * app.memory
+	}
+	return used
+}
+
+//memoryHeadroom works out how much memory is left in the current space and its org
+func memoryHeadroom(cliConnection plugin.CliConnection) (headroom, error) {
+	room := headroom{}
+
+	space, err := cliConnection.GetCurrentSpace()
+	if err != nil {
+		return room, err
+	}
+	spaceModel, err := cliConnection.GetSpace(space.Name)
+	if err != nil {
+		return room, err
+	}
+	if quota := spaceModel.SpaceQuota; quota.Guid != "" && quota.MemoryLimit >= 0 {
+		used, err := spaceMemoryUsage(cliConnection)
+		if err != nil {
+			return room, err
+		}
+		room = headroom{free: quota.MemoryLimit - used, quota: fmt.Sprintf("space quota %s", quota.Name)}
+	}
+
+	org, err := cliConnection.GetCurrentOrg()
+	if err != nil {
+		return room, err
+	}
+	orgModel, err := cliConnection.GetOrg(org.Name)
+	if err != nil {
+		return room, err
+	}
+	if quota := orgModel.QuotaDefinition; quota.Guid != "" && quota.MemoryLimit >= 0 {
+		used, err := orgMemoryUsage(cliConnection, orgModel.Guid)
+		if err != nil {
+			return room, err
+		}
+		if free := quota.MemoryLimit - used; room.quota == "" || free < room.free {
+			room = headroom{free: free, quota: fmt.Sprintf("org quota %s", quota.Name)}
+		}
+	}
+	return room, nil
+}
+
+//spaceMemoryUsage adds up the memory of every started instance in the current space
+func spaceMemoryUsage(cliConnection plugin.CliConnection) (int64, error) {
+	apps, err := cliConnection.GetApps()
+	if err != nil {
+		return 0, err
+	}
+
+	var used int64
+	for _, app := range apps {
+		if app.State == "started" {
+			used += int64(app.TotalInstances) * app.Memory
+		}
+	}
+	return used, nil
+}
+
+//orgMemoryUsage asks the Cloud Controller how much memory the whole org uses
+func orgMemoryUsage(cliConnection plugin.CliConnection, guid string) (int64, error) {
+	var usage struct {
+		MemoryUsageInMB int64 `json:"memory_usage_in_mb"`
+	}
+	err := cfCurl(cliConnection, "/v2/organizations/"+guid+"/memory_usage", &usage)
+	return usage.MemoryUsageInMB, err
+}
+
+//cfCurl reads a Cloud Controller endpoint through cf curl
+func cfCurl(cliConnection plugin.CliConnection, path string, v interface{}) error {
+	output, err := cliConnection.CliCommandWithoutTerminalOutput("curl", path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(strings.Join(output, "\n")), v); err != nil {
+		return fmt.Errorf("Unexpected response from cf curl %s: %s", path, err)
+	}
+	return nil
+}
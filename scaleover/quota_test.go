@@ -0,0 +1,106 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/cli/plugin/models"
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Quota check", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var opts Options
+
+	// quotas sets the space and org memory limits, and how much of the org is in use
+	quotas := func(spaceLimit int64, orgLimit int64, orgUsed string) {
+		space := plugin_models.GetSpace_Model{}
+		if spaceLimit >= 0 {
+			space.SpaceQuota = plugin_models.GetSpace_SpaceQuota{Guid: "space-quota", Name: "small", MemoryLimit: spaceLimit}
+		}
+		fakeCliConnection.GetSpaceReturns(space, nil)
+		fakeCliConnection.GetOrgReturns(plugin_models.GetOrg_Model{
+			Guid:            "org-guid",
+			QuotaDefinition: plugin_models.QuotaFields{Guid: "org-quota", Name: "default", MemoryLimit: orgLimit},
+		}, nil)
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{`{"memory_usage_in_mb": ` + orgUsed + `}`}, nil)
+	}
+
+	BeforeEach(func() {
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.GetAppsReturns([]plugin_models.GetAppsModel{
+			{Name: "blue", State: "started", TotalInstances: 10, Memory: 256},
+			{Name: "green", State: "stopped", TotalInstances: 1, Memory: 256},
+			{Name: "worker", State: "started", TotalInstances: 2, Memory: 512},
+		}, nil)
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRunning: 10, countRequested: 10, state: "started", memory: 256}},
+			targets: []*AppStatus{{name: "green", state: "stopped", memory: 256}},
+		}
+		opts = Options{BatchSize: 4, Strategy: StrategyLinear, Output: OutputText}
+	})
+
+	It("needs one batch of new instances before the old ones go", func() {
+		Ω(scaleoverCmdPlugin.extraMemory(scaleoverCmdPlugin.plan(0, opts))).Should(Equal(int64(1024)))
+	})
+
+	It("leaves the batch size alone when there is room", func() {
+		quotas(8192, 10240, "4608")
+		checked, err := scaleoverCmdPlugin.checkQuota(fakeCliConnection, 0, opts)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(checked.BatchSize).Should(Equal(4))
+	})
+
+	It("reduces the batch size to fit the space quota", func() {
+		// the space uses 3584M of 4096M
+		quotas(4096, 102400, "4608")
+		checked, err := scaleoverCmdPlugin.checkQuota(fakeCliConnection, 0, opts)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(checked.BatchSize).Should(Equal(2))
+	})
+
+	It("reduces the batch size to fit the org quota", func() {
+		quotas(-1, 5120, "4608")
+		checked, err := scaleoverCmdPlugin.checkQuota(fakeCliConnection, 0, opts)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(checked.BatchSize).Should(Equal(2))
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)).Should(Equal([]string{"curl", "/v2/organizations/org-guid/memory_usage"}))
+	})
+
+	It("refuses when not even one more instance fits", func() {
+		quotas(3700, 102400, "4608")
+		_, err := scaleoverCmdPlugin.checkQuota(fakeCliConnection, 0, opts)
+		Ω(err).Should(MatchError("The space quota small only has 116M free, not enough to start even one more 256M instance of green"))
+	})
+
+	It("refuses rather than changing the --steps", func() {
+		quotas(4096, 102400, "4608")
+		opts.Strategy = StrategySteps
+		opts.Steps = []int{50, 100}
+		_, err := scaleoverCmdPlugin.checkQuota(fakeCliConnection, 0, opts)
+		Ω(err).Should(MatchError("The scaleover needs up to 1280M more memory than blue and green use now, but the space quota small only has 512M free"))
+	})
+
+	It("skips the check when the quotas can't be read", func() {
+		fakeCliConnection.GetCurrentSpaceReturns(plugin_models.Space{}, errors.New("not logged in"))
+		checked, err := scaleoverCmdPlugin.checkQuota(fakeCliConnection, 0, opts)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(checked).Should(Equal(opts))
+	})
+})
@@ -0,0 +1,93 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+const defaultRetryBackoff = 5 * time.Second
+
+//RetryPolicy is how often, and how patiently, a failed cf command is retried
+type RetryPolicy struct {
+	Retries int           `json:"retries"`
+	Backoff time.Duration `json:"backoff"`
+}
+
+func newRetryPolicy() RetryPolicy {
+	return RetryPolicy{Backoff: defaultRetryBackoff}
+}
+
+//delay is how long to wait before retry n (counting from 0), doubling each time
+func (policy RetryPolicy) delay(n int) time.Duration {
+	return policy.Backoff << uint(n)
+}
+
+//commandError is a cf command that failed, with whatever cf printed
+type commandError struct {
+	args   []string
+	output []string
+	err    error
+}
+
+func (e *commandError) Error() string {
+	reason := strings.TrimSpace(strings.Join(e.output, " "))
+	if reason == "" {
+		reason = e.err.Error()
+	}
+	return fmt.Sprintf("cf %s failed: %s", strings.Join(e.args, " "), reason)
+}
+
+//runCommand runs a cf command against the app, retrying failures per app.retry
+func (app *AppStatus) runCommand(cliConnection plugin.CliConnection, args []string) error {
+	for n := 0; ; n++ {
+		output, err := app.execute(cliConnection, args)
+		if err == nil {
+			return nil
+		}
+
+		failure := &commandError{args: args, output: output, err: err}
+		if n >= app.retry.Retries {
+			return failure
+		}
+
+		delay := app.retry.delay(n)
+		app.events.emit(Event{Event: EventRetry, App: newAppEvent(app), Command: args, Error: failure.Error()})
+		if app.out != nil && !app.events.toOut() {
+			fmt.Fprintf(app.out, "%s, retrying in %s\n", failure, delay)
+		}
+		orSystemClock(app.clock).Sleep(delay)
+	}
+}
+
+//execute makes API requests with the app's client when it has one, and runs everything else through cf
+func (app *AppStatus) execute(cliConnection plugin.CliConnection, args []string) ([]string, error) {
+	if args[0] == "curl" && app.client != nil {
+		return nil, app.client.curl(args)
+	}
+
+	output, err := cliConnection.CliCommandWithoutTerminalOutput(args...)
+	if err == nil && args[0] == "curl" {
+		// report the Cloud Controller's description rather than its JSON
+		if err = curlError(output); err != nil {
+			output = nil
+		}
+	}
+	return output, err
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Failed cf commands", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var quotaExceeded []string
+
+	BeforeEach(func() {
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		quotaExceeded = []string{"FAILED", "Server error, status code: 400, error code: 100005, message: You have exceeded your organization's memory limit."}
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRunning: 10, countRequested: 10, state: "started"}},
+			targets: []*AppStatus{{name: "green", state: "stopped"}},
+		}
+	})
+
+	It("are reported with what cf printed", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns(quotaExceeded, errors.New("Error executing cli core command"))
+		err := scaleoverCmdPlugin.targets[0].scaleUp(fakeCliConnection, 1)
+		Ω(err).Should(MatchError("cf scale -i 1 green failed: FAILED Server error, status code: 400, error code: 100005, message: You have exceeded your organization's memory limit."))
+	})
+
+	It("fall back to the error when cf printed nothing", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("Error executing cli core command"))
+		err := scaleoverCmdPlugin.sources[0].scaleDown(fakeCliConnection, 9)
+		Ω(err).Should(MatchError("cf scale -i 9 blue failed: Error executing cli core command"))
+	})
+
+	It("stop the scaleover before the source is scaled down", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns(quotaExceeded, errors.New("Error executing cli core command"))
+		err := scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1})
+		Ω(err).Should(HaveOccurred())
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).Should(Equal(1))
+		Ω(scaleoverCmdPlugin.sources[0].countRequested).Should(Equal(10))
+	})
+
+	It("are retried with backoff until they work", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturnsOnCall(0, quotaExceeded, errors.New("Error executing cli core command"))
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturnsOnCall(1, quotaExceeded, errors.New("Error executing cli core command"))
+		scaleoverCmdPlugin.targets[0].retry = RetryPolicy{Retries: 2, Backoff: time.Millisecond}
+
+		Ω(scaleoverCmdPlugin.targets[0].scaleUp(fakeCliConnection, 1)).Should(Succeed())
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).Should(Equal(4))
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(2)).Should(Equal([]string{"scale", "-i", "1", "green"}))
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(3)).Should(Equal([]string{"start", "green"}))
+	})
+
+	It("give up once the retries are used", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns(quotaExceeded, errors.New("Error executing cli core command"))
+		err := scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0,
+			Options{BatchSize: 1, Retry: RetryPolicy{Retries: 2, Backoff: time.Millisecond}})
+		Ω(err).Should(HaveOccurred())
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).Should(Equal(3))
+	})
+
+	It("double the backoff for each retry", func() {
+		policy := RetryPolicy{Retries: 3, Backoff: 5 * time.Second}
+		Ω(policy.delay(0)).Should(Equal(5 * time.Second))
+		Ω(policy.delay(1)).Should(Equal(10 * time.Second))
+		Ω(policy.delay(2)).Should(Equal(20 * time.Second))
+	})
+
+	It("during a rollback leave the target running if the source can't be restored", func() {
+		scaleoverCmdPlugin.origSources = []AppStatus{{name: "blue", countRequested: 10, state: "started"}}
+		scaleoverCmdPlugin.origTargets = []AppStatus{{name: "green", countRequested: 0, state: "stopped"}}
+		scaleoverCmdPlugin.sources[0].countRequested = 5
+		scaleoverCmdPlugin.targets[0] = &AppStatus{name: "green", countRequested: 5, state: "started"}
+		fakeCliConnection.CliCommandWithoutTerminalOutputReturns(quotaExceeded, errors.New("Error executing cli core command"))
+
+		Ω(scaleoverCmdPlugin.rollback(fakeCliConnection)).ShouldNot(Succeed())
+		Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).Should(Equal(1))
+		Ω(scaleoverCmdPlugin.targets[0].state).Should(Equal("started"))
+	})
+})
@@ -0,0 +1,191 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+//Route protocols
+const (
+	protocolHTTP = "http"
+	protocolTCP  = "tcp"
+)
+
+const wildcardHost = "*"
+
+//route is an HTTP route (host, domain and path) or a TCP route (domain and port)
+type route struct {
+	host   string // empty for a route on the bare domain, * for a wildcard
+	domain string
+	path   string
+	port   int
+	guid   string // known for routes read from the Cloud Controller, or looked up to map across spaces
+}
+
+func (r route) protocol() string {
+	if r.port > 0 {
+		return protocolTCP
+	}
+	return protocolHTTP
+}
+
+func (r route) isWildcard() bool {
+	return r.host == wildcardHost
+}
+
+func (r route) String() string {
+	if r.protocol() == protocolTCP {
+		return r.domain + ":" + strconv.Itoa(r.port)
+	}
+	name := r.domain
+	if r.host != "" {
+		name = r.host + "." + name
+	}
+	return name + r.path
+}
+
+//same is true when both are the one route. Hosts and domains ignore case, paths don't.
+//A wildcard is only the same as the identical wildcard: *.example.com on one app and
+//www.example.com on the other don't share traffic for www, the more specific route wins it.
+func (r route) same(other route) bool {
+	return strings.EqualFold(r.host, other.host) &&
+		strings.EqualFold(r.domain, other.domain) &&
+		strings.TrimSuffix(r.path, "/") == strings.TrimSuffix(other.path, "/") &&
+		r.port == other.port
+}
+
+//parseRoute splits HOST.DOMAIN[/PATH] or DOMAIN:PORT, preferring the longest of the
+//known domains and otherwise taking everything after the first dot as the domain
+func parseRoute(url string, domains []string) route {
+	r := route{}
+	if colon := strings.LastIndex(url, ":"); colon >= 0 {
+		if port, err := strconv.Atoi(url[colon+1:]); err == nil {
+			return route{domain: url[:colon], port: port}
+		}
+	}
+	if slash := strings.Index(url, "/"); slash >= 0 {
+		url, r.path = url[:slash], url[slash:]
+	}
+
+	for _, domain := range domains {
+		if len(domain) <= len(r.domain) {
+			continue
+		}
+		if strings.EqualFold(url, domain) {
+			r.host, r.domain = "", domain
+		} else if suffix := "." + domain; len(url) > len(suffix) && strings.EqualFold(url[len(url)-len(suffix):], suffix) {
+			r.host, r.domain = url[:len(url)-len(suffix)], domain
+		}
+	}
+	if r.domain == "" {
+		if dot := strings.Index(url, "."); dot >= 0 {
+			r.host, r.domain = url[:dot], url[dot+1:]
+		} else {
+			r.domain = url
+		}
+	}
+	return r
+}
+
+//lookupRoutes parses routes given on the command line against the domains available in the current space
+func lookupRoutes(cliConnection plugin.CliConnection, urls []string) []route {
+	var domains []string
+	if space, err := cliConnection.GetCurrentSpace(); err == nil {
+		if spaceModel, err := cliConnection.GetSpace(space.Name); err == nil {
+			for _, domain := range spaceModel.Domains {
+				domains = append(domains, domain.Name)
+			}
+		}
+	}
+
+	routes := make([]route, len(urls))
+	for i, url := range urls {
+		routes[i] = parseRoute(url, domains)
+	}
+	return routes
+}
+
+func (r route) args(command string, appName string) []string {
+	args := []string{command, appName, r.domain}
+	if r.protocol() == protocolTCP {
+		return append(args, "--port", strconv.Itoa(r.port))
+	}
+	if r.host != "" {
+		args = append(args, "--hostname", r.host)
+	}
+	if r.path != "" {
+		args = append(args, "--path", r.path)
+	}
+	return args
+}
+
+func (app *AppStatus) hasRoute(r route) bool {
+	for _, mapped := range app.routes {
+		if mapped.same(r) {
+			return true
+		}
+	}
+	return false
+}
+
+//wildcardRoute finds a route on the app that would be r if it weren't, or were, a wildcard.
+//Both get some of the same requests, but neither is the other, so it explains a missing route.
+func (app *AppStatus) wildcardRoute(r route) (route, bool) {
+	for _, mapped := range app.routes {
+		if r.host == "" || mapped.host == "" || mapped.isWildcard() == r.isWildcard() {
+			continue
+		}
+		if r.host = mapped.host; mapped.same(r) {
+			return mapped, true
+		}
+	}
+	return route{}, false
+}
+
+//mapRouteCommands adds the route to the app unless it's already there
+func (app *AppStatus) mapRouteCommands(r route) [][]string {
+	if app.hasRoute(r) {
+		return nil
+	}
+	app.routes = append(app.routes, r)
+	return [][]string{app.routeCommand("map-route", r)}
+}
+
+//unmapRouteCommands takes the route off the app if it's there
+func (app *AppStatus) unmapRouteCommands(r route) [][]string {
+	if !app.hasRoute(r) {
+		return nil
+	}
+	var routes []route
+	for _, mapped := range app.routes {
+		if !mapped.same(r) {
+			routes = append(routes, mapped)
+		}
+	}
+	app.routes = routes
+	return [][]string{app.routeCommand("unmap-route", r)}
+}
+
+//restoreRouteCommands maps or unmaps the route so the app has it only if orig did
+func (app *AppStatus) restoreRouteCommands(r route, orig AppStatus) [][]string {
+	if orig.hasRoute(r) {
+		return app.mapRouteCommands(r)
+	}
+	return app.unmapRouteCommands(r)
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"bytes"
+
+	"code.cloudfoundry.org/cli/plugin/models"
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Route management", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var prod route
+
+	commands := func() [][]string {
+		var issued [][]string
+		for i := 0; i < fakeCliConnection.CliCommandWithoutTerminalOutputCallCount(); i++ {
+			issued = append(issued, fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(i))
+		}
+		return issued
+	}
+
+	BeforeEach(func() {
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		prod = route{host: "node-prod", domain: "cfapps.io"}
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRunning: 2, countRequested: 2, state: "started", routes: []route{{host: "blue", domain: "cfapps.io"}, prod}}},
+			targets: []*AppStatus{{name: "green", state: "stopped", routes: []route{{host: "green", domain: "cfapps.io"}}}},
+			route:   &prod,
+		}
+		scaleoverCmdPlugin.origSources = snapshot(scaleoverCmdPlugin.sources)
+		scaleoverCmdPlugin.origTargets = snapshot(scaleoverCmdPlugin.targets)
+	})
+
+	Describe("parseRoute", func() {
+		It("splits the host from a known domain", func() {
+			Ω(parseRoute("node-prod.apps.example.com", []string{"example.com", "apps.example.com"})).Should(Equal(route{host: "node-prod", domain: "apps.example.com"}))
+		})
+
+		It("maps the bare domain when that's all there is", func() {
+			Ω(parseRoute("apps.example.com/api", []string{"apps.example.com"})).Should(Equal(route{domain: "apps.example.com", path: "/api"}))
+		})
+
+		It("reads a TCP route's port", func() {
+			Ω(parseRoute("tcp.example.com:1024", []string{"example.com"})).Should(Equal(route{domain: "tcp.example.com", port: 1024}))
+		})
+
+		It("keeps a wildcard host", func() {
+			Ω(parseRoute("*.apps.example.com", []string{"apps.example.com"})).Should(Equal(route{host: "*", domain: "apps.example.com"}))
+		})
+
+		It("takes everything after the first dot as the domain otherwise", func() {
+			Ω(parseRoute("node-prod.cfapps.io/v2/api", nil)).Should(Equal(route{host: "node-prod", domain: "cfapps.io", path: "/v2/api"}))
+		})
+
+		It("looks the domains up in the current space", func() {
+			fakeCliConnection.GetSpaceReturns(plugin_models.GetSpace_Model{
+				Domains: []plugin_models.GetSpace_Domains{{Name: "example.com"}, {Name: "apps.example.com"}},
+			}, nil)
+			Ω(lookupRoutes(fakeCliConnection, []string{"www.apps.example.com", "apps.example.com/api"})).Should(Equal([]route{
+				{host: "www", domain: "apps.example.com"},
+				{domain: "apps.example.com", path: "/api"},
+			}))
+		})
+	})
+
+	Describe("route", func() {
+		It("prints HTTP routes as host, domain and path", func() {
+			Ω(route{host: "www", domain: "example.com", path: "/api"}.String()).Should(Equal("www.example.com/api"))
+		})
+
+		It("prints a route on the bare domain without a leading dot", func() {
+			Ω(route{domain: "example.com"}.String()).Should(Equal("example.com"))
+		})
+
+		It("prints TCP routes as domain and port", func() {
+			r := route{domain: "tcp.example.com", port: 1024}
+			Ω(r.protocol()).Should(Equal(protocolTCP))
+			Ω(r.String()).Should(Equal("tcp.example.com:1024"))
+		})
+
+		It("ignores case in hosts and domains but not paths", func() {
+			www := route{host: "www", domain: "example.com", path: "/api"}
+			Ω(www.same(route{host: "WWW", domain: "Example.com", path: "/api/"})).Should(BeTrue())
+			Ω(www.same(route{host: "www", domain: "example.com", path: "/API"})).Should(BeFalse())
+			Ω(www.same(route{host: "www", domain: "example.com"})).Should(BeFalse())
+		})
+
+		It("tells TCP ports apart", func() {
+			Ω(route{domain: "tcp.example.com", port: 1024}.same(route{domain: "tcp.example.com", port: 1025})).Should(BeFalse())
+		})
+
+		It("only matches a wildcard with the same wildcard", func() {
+			wildcard := route{host: "*", domain: "example.com"}
+			Ω(wildcard.isWildcard()).Should(BeTrue())
+			Ω(wildcard.same(route{host: "*", domain: "example.com"})).Should(BeTrue())
+			Ω(wildcard.same(route{host: "www", domain: "example.com"})).Should(BeFalse())
+			Ω(wildcard.same(route{domain: "example.com"})).Should(BeFalse())
+		})
+
+		It("maps TCP routes by port", func() {
+			Ω(route{domain: "tcp.example.com", port: 1024}.args("map-route", "green")).Should(Equal([]string{"map-route", "green", "tcp.example.com", "--port", "1024"}))
+		})
+	})
+
+	It("maps the route to the new app before scaling it up", func() {
+		scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1})
+		Ω(commands()[0]).Should(Equal([]string{"map-route", "green", "cfapps.io", "--hostname", "node-prod"}))
+		Ω(scaleoverCmdPlugin.targets[0].hasRoute(prod)).Should(BeTrue())
+	})
+
+	It("leaves the route alone when the new app already has it", func() {
+		scaleoverCmdPlugin.targets[0].routes = append(scaleoverCmdPlugin.targets[0].routes, route{host: "NODE-PROD", domain: "cfapps.io"})
+		scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1})
+		Ω(commands()[0]).Should(Equal([]string{"scale", "-i", "1", "green"}))
+	})
+
+	It("doesn't unmap the old app without --unmap-old", func() {
+		scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1})
+		Ω(scaleoverCmdPlugin.sources[0].hasRoute(prod)).Should(BeTrue())
+	})
+
+	It("unmaps the old app once it's down to its --leave count", func() {
+		scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1, Leave: 1, UnmapOld: true})
+		Ω(commands()[4]).Should(Equal([]string{"unmap-route", "blue", "cfapps.io", "--hostname", "node-prod"}))
+		Ω(scaleoverCmdPlugin.sources[0].hasRoute(prod)).Should(BeFalse())
+		Ω(scaleoverCmdPlugin.sources[0].countRequested).Should(Equal(1))
+	})
+
+	It("puts the routes back on rollback", func() {
+		scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1, UnmapOld: true})
+		scaleoverCmdPlugin.rollback(fakeCliConnection)
+		Ω(scaleoverCmdPlugin.sources[0].hasRoute(prod)).Should(BeTrue())
+		Ω(scaleoverCmdPlugin.targets[0].hasRoute(prod)).Should(BeFalse())
+		Ω(commands()).Should(ContainElement([]string{"map-route", "blue", "cfapps.io", "--hostname", "node-prod"}))
+		Ω(commands()).Should(ContainElement([]string{"unmap-route", "green", "cfapps.io", "--hostname", "node-prod"}))
+	})
+
+	It("shows the route changes in the plan", func() {
+		run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 2, UnmapOld: true, Strategy: StrategyLinear})
+		out := &bytes.Buffer{}
+		scaleoverCmdPlugin.printPlan(out, run)
+		Ω(out.String()).Should(ContainSubstring("Step 1 at +0s\n  cf map-route green cfapps.io --hostname node-prod\n"))
+		Ω(out.String()).Should(ContainSubstring("  cf unmap-route blue cfapps.io --hostname node-prod\n"))
+		Ω(scaleoverCmdPlugin.targets[0].hasRoute(prod)).Should(BeFalse())
+	})
+
+	Describe("--require-route", func() {
+		BeforeEach(func() {
+			scaleoverCmdPlugin.route = nil
+		})
+
+		It("passes when every required route is on both apps", func() {
+			scaleoverCmdPlugin.targets[0].routes = append(scaleoverCmdPlugin.targets[0].routes, prod)
+			Ω(scaleoverCmdPlugin.checkRoutes(fakeCliConnection, Options{RequireRoutes: []string{"node-prod.cfapps.io"}})).Should(Succeed())
+		})
+
+		It("fails even though the apps share some other route", func() {
+			scaleoverCmdPlugin.targets[0].routes = append(scaleoverCmdPlugin.targets[0].routes, route{host: "blue", domain: "cfapps.io"})
+			err := scaleoverCmdPlugin.checkRoutes(fakeCliConnection, Options{EnforceRoutes: true, RequireRoutes: []string{"node-prod.cfapps.io"}})
+			Ω(err).Should(MatchError("Required routes are missing:\n\tnode-prod.cfapps.io is not mapped to green"))
+		})
+
+		It("reports each missing route on each app", func() {
+			err := scaleoverCmdPlugin.checkRoutes(fakeCliConnection, Options{RequireRoutes: []string{"node-prod.cfapps.io", "api.cfapps.io/v2"}})
+			Ω(err).Should(MatchError("Required routes are missing:\n" +
+				"\tnode-prod.cfapps.io is not mapped to green\n" +
+				"\tapi.cfapps.io/v2 is not mapped to blue\n" +
+				"\tapi.cfapps.io/v2 is not mapped to green"))
+		})
+
+		It("points out a wildcard standing in for a required route", func() {
+			scaleoverCmdPlugin.targets[0].routes = append(scaleoverCmdPlugin.targets[0].routes, route{host: "*", domain: "cfapps.io"})
+			err := scaleoverCmdPlugin.checkRoutes(fakeCliConnection, Options{RequireRoutes: []string{"node-prod.cfapps.io"}})
+			Ω(err).Should(MatchError("Required routes are missing:\n\tnode-prod.cfapps.io is not mapped to green, it has *.cfapps.io instead"))
+		})
+
+		It("doesn't need the --map-route route on the new app yet", func() {
+			opts := Options{MapRoute: "node-prod.cfapps.io", RequireRoutes: []string{"node-prod.cfapps.io"}}
+			Ω(scaleoverCmdPlugin.checkRoutes(fakeCliConnection, opts)).Should(Succeed())
+		})
+	})
+})
@@ -0,0 +1,769 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+//AppStatus represents the sattus of a app in CF
+type AppStatus struct {
+	name           string
+	guid           string // apps with a guid are scaled through the API rather than cf scale
+	spaceGUID      string
+	countRunning   int
+	countRequested int
+	stoppedCount   int // instances a stopped app is scaled to, its countRequested is 0
+	state          string
+	routes         []route
+	memory         int64 // MB per instance
+	weight         int   // share of the targets' instances, targets only
+
+	events *eventLog
+	out    io.Writer // where retries are reported, nil for nowhere
+	retry  RetryPolicy
+	client *ccClient // nil to make requests through cf curl
+	clock  Clock     // nil for the system clock
+}
+
+//Scaleover moves instances from source apps to target apps a step at a time.
+//The zero value is ready to Run or Resume.
+type Scaleover struct {
+	//Clock times every wait, poll and deadline, the system clock when nil
+	Clock Clock
+	//OnEvent, when set, is called with every event as it happens
+	OnEvent func(Event)
+	//Out gets the progress messages, the --dry-run plan and the --output json events, nothing is written when nil
+	Out io.Writer
+	//OnStatus, when set, shows the sources' and targets' counts in place of the status line written to Out
+	OnStatus func(app1 *AppEvent, app2 *AppEvent)
+	//Interrupts stops the scaleover between steps whenever a signal arrives on it, never when nil
+	Interrupts <-chan os.Signal
+	//OnInterrupt, when set, chooses what to do about an interrupt, given the --on-interrupt policy
+	//it would otherwise follow. It returns one of InterruptContinue, Hold, Abort or Rollback.
+	OnInterrupt func(policy string) string
+
+	sources  []*AppStatus
+	targets  []*AppStatus
+	maxcount int
+
+	// snapshots of every app taken before anything was scaled, used to roll back
+	origSources []AppStatus
+	origTargets []AppStatus
+
+	// the route --map-route moves over, nil when routes are left alone
+	route *route
+
+	events *eventLog
+	client *ccClient
+}
+
+//Options are the settings of a scaleover, the cf plugin fills them in from its flags
+type Options struct {
+	EnforceRoutes     bool               `json:"enforceRoutes"`
+	QuotaCheck        bool               `json:"-"`
+	Leave             int                `json:"leave"`
+	WaitForStarted    bool               `json:"waitForStarted"`
+	PostStartSleep    time.Duration      `json:"postStartSleep"`
+	BatchSize         int                `json:"batchSize"`
+	RollbackOnFailure bool               `json:"rollbackOnFailure"`
+	OnInterrupt       string             `json:"onInterrupt"`
+	HealthCheck       HealthCheck        `json:"healthCheck"`
+	Retry             RetryPolicy        `json:"retry"`
+	Strategy          string             `json:"strategy"`
+	Steps             []int              `json:"steps,omitempty"`
+	StateFile         string             `json:"-"`
+	DryRun            bool               `json:"-"`
+	RequireRoutes     []string           `json:"requireRoutes,omitempty"`
+	MapRoute          string             `json:"mapRoute,omitempty"`
+	UnmapOld          bool               `json:"unmapOld"`
+	Output            string             `json:"output"`
+	LogFile           string             `json:"logFile,omitempty"`
+	Hooks             Hooks              `json:"hooks"`
+	StartTimeout      time.Duration      `json:"startTimeout"`
+	OnStartFailure    StartFailurePolicy `json:"onStartFailure"`
+	Balance           string             `json:"balance,omitempty"`
+	MinAvailable      *Amount            `json:"minAvailable,omitempty"`
+	MaxSurge          *Amount            `json:"maxSurge,omitempty"`
+	LeavePercent      int                `json:"-"`
+	BatchSizePercent  int                `json:"-"`
+	TargetInstances   int                `json:"targetInstances,omitempty"`
+	CapacityMatch     string             `json:"capacityMatch,omitempty"`
+}
+
+//DefaultOptions are the settings for anything that isn't asked for
+func DefaultOptions() Options {
+	return Options{
+		EnforceRoutes: true,
+		QuotaCheck:    true,
+		BatchSize:     1,
+		StateFile:     DefaultStateFile(),
+		OnInterrupt:   InterruptHold,
+		HealthCheck:   newHealthCheck(),
+		Retry:         newRetryPolicy(),
+		Strategy:      StrategyLinear,
+		Output:        OutputText,
+		StartTimeout:  defaultStartTimeout,
+		Balance:       BalancePlan,
+	}
+}
+
+//Run scales the sources over to the targets across rolloverTime, weights[i] being targetNames[i]'s
+//share of the instances. Progress and errors are written to Out unless opts.Output is json, and errors returned.
+func (cmd *Scaleover) Run(cliConnection plugin.CliConnection, sourceNames []string, targetNames []string,
+	weights []int, rolloverTime time.Duration, opts Options) error {
+	cmd.reset()
+	var err error
+	if cmd.events, err = newEventLog(opts, cmd.Clock, cmd.OnEvent, cmd.Out); nil != err {
+		return cmd.fail(err)
+	}
+	defer cmd.events.Close()
+
+	if err = cmd.getApps(cliConnection, sourceNames, targetNames, weights); nil != err {
+		return cmd.fail(err)
+	}
+
+	if err = cmd.checkRoutes(cliConnection, opts); err != nil {
+		return cmd.fail(err)
+	}
+
+	cmd.showStatus()
+
+	if requested(cmd.sources) == 0 {
+		cmd.say("\nThere are no instances of the source app to scale over\n\n")
+		cmd.events.emit(Event{Event: EventFinished, App1: newGroupEvent(cmd.sources), App2: newGroupEvent(cmd.targets)})
+		return nil
+	}
+
+	opts = resolvePercentages(opts, requested(cmd.sources))
+	if opts.Leave > requested(cmd.sources) {
+		return cmd.fail(fmt.Errorf("Can't leave %d instances of %s, it only has %d",
+			opts.Leave, names(cmd.sources), requested(cmd.sources)))
+	}
+
+	cmd.origSources = snapshot(cmd.sources)
+	cmd.origTargets = snapshot(cmd.targets)
+
+	if opts, err = cmd.resolveTarget(opts); err != nil {
+		return cmd.fail(err)
+	}
+
+	if err = cmd.checkCapacityLimits(rolloverTime, opts); err != nil {
+		return cmd.fail(err)
+	}
+
+	if opts.QuotaCheck {
+		if opts, err = cmd.checkQuota(cliConnection, rolloverTime, opts); err != nil {
+			return cmd.fail(err)
+		}
+	}
+
+	run := cmd.newRunState(rolloverTime, opts)
+	cmd.events.emit(Event{Event: EventPlan, Strategy: opts.Strategy, Plan: run.Steps,
+		App1: newGroupEvent(cmd.sources), App2: newGroupEvent(cmd.targets)})
+	if opts.DryRun {
+		if !cmd.events.toOut() {
+			fmt.Fprintln(cmd.out())
+			cmd.printPlan(cmd.out(), run)
+		}
+		return nil
+	}
+	if err = cmd.runHooks(hookBefore, opts.Hooks.Before); err != nil {
+		return cmd.fail(err)
+	}
+	return cmd.runScaleover(cliConnection, run)
+}
+
+//resolvePercentages turns a percentage --leave or --batch-size into that share of the
+//sources' total instances, rounded up and never less than one
+func resolvePercentages(opts Options, total int) Options {
+	if opts.LeavePercent > 0 {
+		opts.Leave = atLeastOne(Amount{N: opts.LeavePercent, Percent: true}.resolve(total))
+	}
+	if opts.BatchSizePercent > 0 {
+		opts.BatchSize = atLeastOne(Amount{N: opts.BatchSizePercent, Percent: true}.resolve(total))
+	}
+	return opts
+}
+
+//Resume picks up a scaleover from the state file left behind by an interrupted Run
+func (cmd *Scaleover) Resume(cliConnection plugin.CliConnection, stateFile string) error {
+	cmd.reset()
+	run, err := loadRunState(stateFile)
+	if nil != err {
+		return cmd.fail(err)
+	}
+	run.Options.StateFile = stateFile
+
+	if cmd.events, err = newEventLog(run.Options, cmd.Clock, cmd.OnEvent, cmd.Out); nil != err {
+		return cmd.fail(err)
+	}
+	defer cmd.events.Close()
+
+	var sourceNames, targetNames []string
+	var weights []int
+	for _, app := range run.Sources {
+		sourceNames = append(sourceNames, app.Name)
+	}
+	for _, app := range run.Targets {
+		targetNames = append(targetNames, app.Name)
+		weights = append(weights, app.Weight)
+	}
+	if err = cmd.getApps(cliConnection, sourceNames, targetNames, weights); nil != err {
+		return cmd.fail(err)
+	}
+
+	if err = cmd.checkRoutes(cliConnection, run.Options); err != nil {
+		return cmd.fail(err)
+	}
+
+	cmd.origSources = appStatuses(run.Sources)
+	cmd.origTargets = appStatuses(run.Targets)
+
+	cmd.say("Resuming scaleover of %s to %s after step %d of %d\n", names(cmd.sources), names(cmd.targets), run.Step, len(run.Steps))
+	cmd.showStatus()
+	return cmd.runScaleover(cliConnection, run)
+}
+
+//reset clears the apps and anything else left over from an earlier Run or Resume
+func (cmd *Scaleover) reset() {
+	*cmd = Scaleover{Clock: cmd.Clock, OnEvent: cmd.OnEvent, Out: cmd.Out, OnStatus: cmd.OnStatus,
+		Interrupts: cmd.Interrupts, OnInterrupt: cmd.OnInterrupt}
+}
+
+//getApps looks up every source and target, the call fails if any of them don't exist
+func (cmd *Scaleover) getApps(cliConnection plugin.CliConnection, sourceNames []string, targetNames []string, weights []int) error {
+	client, err := newCCClient(cliConnection)
+	if err != nil {
+		cmd.say("Making requests through cf curl: %s\n", err)
+	}
+	cmd.client = client
+
+	for _, name := range sourceNames {
+		app, err := cmd.getAppStatus(cliConnection, name)
+		if nil != err {
+			return err
+		}
+		cmd.sources = append(cmd.sources, app)
+	}
+
+	for i, name := range targetNames {
+		app, err := cmd.getAppStatus(cliConnection, name)
+		if nil != err {
+			return err
+		}
+		app.weight = weights[i]
+		cmd.targets = append(cmd.targets, app)
+	}
+	return nil
+}
+
+//runScaleover drives the run to completion, rolling back on failure
+func (cmd *Scaleover) runScaleover(cliConnection plugin.CliConnection, run *runState) error {
+	cmd.saveRunState(run)
+
+	if err := cmd.continueScaleover(cliConnection, run); err != nil {
+		cmd.say("\n%s\n", err)
+		cmd.events.emit(Event{Event: EventFailed, Error: err.Error(), Step: run.Step, Steps: len(run.Steps),
+			App1: newGroupEvent(cmd.sources), App2: newGroupEvent(cmd.targets)})
+
+		rollback, keepState := run.Options.RollbackOnFailure, true
+		if interrupted, ok := err.(*interruptedError); ok {
+			rollback = interrupted.action == InterruptRollback
+			keepState = interrupted.action == InterruptHold
+		}
+		if failed, ok := err.(*startError); ok {
+			rollback = failed.outcome == StartRollback
+		}
+		if rollback {
+			if err := cmd.rollback(cliConnection); err != nil {
+				cmd.say("Rollback failed: %s\n", err)
+			} else {
+				keepState = false
+			}
+		}
+		if !keepState {
+			removeRunState(run.Options.StateFile)
+		}
+		if hookErr := cmd.runHooks(hookOnFailure, run.Options.Hooks.OnFailure); hookErr != nil {
+			cmd.say("%s\n", hookErr)
+		}
+		return err
+	}
+	removeRunState(run.Options.StateFile)
+	cmd.events.emit(Event{Event: EventFinished, App1: newGroupEvent(cmd.sources), App2: newGroupEvent(cmd.targets)})
+	cmd.say("\n")
+	if err := cmd.runHooks(hookAfter, run.Options.Hooks.After); err != nil {
+		cmd.say("%s\n", err)
+		return err
+	}
+	return nil
+}
+
+//say prints progress for people, unless Out is taken by --output json
+func (cmd *Scaleover) say(format string, a ...interface{}) {
+	if !cmd.events.toOut() {
+		fmt.Fprintf(cmd.out(), format, a...)
+	}
+}
+
+//out is where progress goes, Out or nowhere
+func (cmd *Scaleover) out() io.Writer {
+	if cmd.Out == nil {
+		return ioutil.Discard
+	}
+	return cmd.Out
+}
+
+//fail reports an error that stops the scaleover before anything was scaled, and returns it
+func (cmd *Scaleover) fail(err error) error {
+	cmd.events.emit(Event{Event: EventFailed, Error: err.Error(), App1: newGroupEvent(cmd.sources), App2: newGroupEvent(cmd.targets)})
+	cmd.say("%s\n", err)
+	return err
+}
+
+func (cmd *Scaleover) doScaleover(cliConnection plugin.CliConnection,
+	rolloverTime time.Duration, opts Options) error {
+	return cmd.continueScaleover(cliConnection, cmd.newRunState(rolloverTime, opts))
+}
+
+//newRunState plans every step of the scaleover with the chosen strategy
+func (cmd *Scaleover) newRunState(rolloverTime time.Duration, opts Options) *runState {
+	return &runState{
+		Sources: newSavedApps(cmd.origSources),
+		Targets: newSavedApps(cmd.origTargets),
+		Steps:   cmd.plan(rolloverTime, opts),
+		Options: opts,
+	}
+}
+
+//plan works out the steps from where the apps are now. Steps count the instances of
+//all the sources and all the targets, sourceCounts and targetCounts split them up.
+func (cmd *Scaleover) plan(rolloverTime time.Duration, opts Options) []Step {
+	steps := newStrategy(opts).Plan(cmd.rollout(rolloverTime, opts))
+	// checkCapacityLimits has already turned down any plan the limits can't be kept to
+	if limited, err := cmd.capacityLimits(opts).apply(requested(cmd.sources), requested(cmd.targets), steps); err == nil {
+		return limited
+	}
+	return steps
+}
+
+//rollout is where the apps are now and where the options say they should end up
+func (cmd *Scaleover) rollout(rolloverTime time.Duration, opts Options) Rollout {
+	target := requested(cmd.sources)
+	if opts.TargetInstances > 0 {
+		target = opts.TargetInstances
+	}
+	return Rollout{
+		App1:        requested(cmd.sources),
+		App2:        requested(cmd.targets),
+		App2Running: running(cmd.targets),
+		Target:      target,
+		Leave:       opts.Leave,
+		BatchSize:   opts.BatchSize,
+		Duration:    rolloverTime,
+	}
+}
+
+//continueScaleover runs the remaining steps, saving progress after each one
+func (cmd *Scaleover) continueScaleover(cliConnection plugin.CliConnection, run *runState) error {
+	opts := run.Options
+	limits := cmd.capacityLimits(opts)
+	for _, app := range cmd.apps() {
+		app.retry = opts.Retry
+	}
+	if cmd.route != nil {
+		for _, app := range cmd.targets {
+			if err := app.issue(cliConnection, app.mapRouteCommands(*cmd.route)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for run.Step < len(run.Steps) {
+		if err := cmd.checkInterrupt(run); err != nil {
+			return err
+		}
+
+		next := run.Steps[run.Step]
+		if next.DownFirst {
+			if err := cmd.scaleSourcesDown(cliConnection, next.App1); err != nil {
+				return err
+			}
+		}
+		for i, count := range cmd.targetCounts(next.App2) {
+			if err := cmd.targets[i].scaleUp(cliConnection, count); err != nil {
+				return err
+			}
+		}
+		// balancing by readiness needs to know what's running, so it always waits
+		if opts.WaitForStarted || opts.Balance == BalanceReady {
+			if err := cmd.waitForTargets(cliConnection, opts); err != nil {
+				return err
+			}
+		}
+		// probe once the new instances are up, so starting doesn't use up --health-timeout
+		if opts.HealthCheck.URL != "" {
+			err := opts.HealthCheck.wait(orSystemClock(cmd.Clock))
+			cmd.events.emit(healthEvent(opts.HealthCheck, err))
+			if err != nil {
+				return err
+			}
+		}
+		if !next.DownFirst {
+			sourceTotal := next.App1
+			if opts.Balance == BalanceReady {
+				sourceTotal = cmd.readySourceTotal(next.App1, opts.TargetInstances)
+			}
+			if err := cmd.scaleSourcesDown(cliConnection, sourceTotal); err != nil {
+				return err
+			}
+		}
+		if limits.minAvailable > 0 || limits.maxRequested > 0 {
+			if err := cmd.verifyCapacity(cliConnection, limits); err != nil {
+				return err
+			}
+		}
+		if cmd.unmapsOld(run, cmd.sources) {
+			for _, app := range cmd.sources {
+				if err := app.issue(cliConnection, app.unmapRouteCommands(*cmd.route)); err != nil {
+					return err
+				}
+			}
+		}
+
+		run.Step++
+		cmd.saveRunState(run)
+		cmd.events.emit(Event{Event: EventStep, Step: run.Step, Steps: len(run.Steps),
+			App1: newGroupEvent(cmd.sources), App2: newGroupEvent(cmd.targets)})
+		cmd.showStatus()
+		if run.Step < len(run.Steps) {
+			if err := cmd.pause(run, next.Wait); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//scaleSourcesDown takes the sources down to total instances between them
+func (cmd *Scaleover) scaleSourcesDown(cliConnection plugin.CliConnection, total int) error {
+	for i, count := range cmd.sourceCounts(total) {
+		if err := cmd.sources[i].scaleDown(cliConnection, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//unmapsOld is true when the sources are down to their --leave count and the route should come off them.
+//It goes by what the sources have rather than the plan, which --balance ready may have kept them above.
+func (cmd *Scaleover) unmapsOld(run *runState, sources []*AppStatus) bool {
+	return cmd.route != nil && run.Options.UnmapOld && requestedStarted(sources) <= run.Options.Leave
+}
+
+//checkRoutes works out the route --map-route names, if any, and makes sure the apps
+//have the routes --require-route lists, or failing that share some route
+func (cmd *Scaleover) checkRoutes(cliConnection plugin.CliConnection, opts Options) error {
+	if opts.MapRoute != "" {
+		cmd.route = &lookupRoutes(cliConnection, []string{opts.MapRoute})[0]
+		if cmd.qualified() {
+			guid, err := findRouteGUID(cliConnection, *cmd.route)
+			if err != nil {
+				return err
+			}
+			cmd.route.guid = guid
+		}
+	}
+
+	if len(opts.RequireRoutes) > 0 {
+		return cmd.errorIfMissingRoutes(lookupRoutes(cliConnection, opts.RequireRoutes))
+	}
+	if opts.EnforceRoutes && cmd.route == nil {
+		return cmd.errorIfNoSharedRoute()
+	}
+	return nil
+}
+
+//saveRunState records progress, warning rather than failing if it can't
+func (cmd *Scaleover) saveRunState(run *runState) {
+	if run.Options.StateFile == "" {
+		return
+	}
+	if err := run.save(run.Options.StateFile); err != nil {
+		cmd.say("\nUnable to save scaleover state to %s: %s\n", run.Options.StateFile, err)
+	}
+}
+
+//rollback puts every app back the way it was before the scaleover started
+func (cmd *Scaleover) rollback(cliConnection plugin.CliConnection) error {
+	cmd.say("Rolling back %s and %s to their original instance counts\n", names(cmd.sources), names(cmd.targets))
+	// restore the sources first so capacity comes back before the targets go away,
+	// and leave the targets alone if the sources can't be restored
+	err := cmd.restore(cliConnection, cmd.sources, cmd.origSources)
+	if err == nil {
+		err = cmd.restore(cliConnection, cmd.targets, cmd.origTargets)
+	}
+
+	rolledBack := Event{Event: EventRollback, App1: newGroupEvent(cmd.sources), App2: newGroupEvent(cmd.targets)}
+	if err != nil {
+		rolledBack.Error = err.Error()
+	}
+	cmd.events.emit(rolledBack)
+	cmd.showStatus()
+	cmd.say("\n")
+	return err
+}
+
+//restore puts each app back to its snapshot in orig, along with the --map-route route
+func (cmd *Scaleover) restore(cliConnection plugin.CliConnection, apps []*AppStatus, orig []AppStatus) error {
+	for i, app := range apps {
+		if i >= len(orig) {
+			break
+		}
+		if err := app.restore(cliConnection, orig[i]); err != nil {
+			return err
+		}
+		if cmd.route != nil {
+			if err := app.issue(cliConnection, app.restoreRouteCommands(*cmd.route, orig[i])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (cmd *Scaleover) getAppStatus(cliConnection plugin.CliConnection, name string) (*AppStatus, error) {
+	if org, _, _ := splitAppID(name); org != "" {
+		return cmd.getQualifiedAppStatus(cliConnection, name)
+	}
+
+	app, err := cliConnection.GetApp(name)
+	if nil != err {
+		return nil, err
+	}
+
+	status := &AppStatus{
+		name:           name,
+		guid:           app.Guid,
+		spaceGUID:      app.SpaceGuid,
+		countRunning:   0,
+		countRequested: 0,
+		state:          "unknown",
+		routes:         make([]route, len(app.Routes)),
+		memory:         app.Memory,
+		events:         cmd.events,
+		out:            cmd.out(),
+		client:         cmd.client,
+		clock:          cmd.Clock,
+	}
+
+	status.state = app.State
+	if app.State != "stopped" {
+		status.countRequested = app.InstanceCount
+	} else {
+		status.stoppedCount = app.InstanceCount
+	}
+	status.countRunning = app.RunningInstances
+	for idx, r := range app.Routes {
+		status.routes[idx] = route{host: r.Host, domain: r.Domain.Name, path: r.Path, port: r.Port, guid: r.Guid}
+	}
+	return status, nil
+}
+
+func (app *AppStatus) scaleUp(cliConnection plugin.CliConnection, target int) error {
+	if !app.needsScaleUp(target) {
+		return nil
+	}
+	return app.issue(cliConnection, app.scaleUpCommands(target))
+}
+
+//scaleUpCommands updates the app to target instances and returns the cf commands that do it
+func (app *AppStatus) scaleUpCommands(target int) [][]string {
+	app.countRequested = target
+	commands := [][]string{app.scaleCommand(app.countRequested)}
+
+	// If not already started, start it
+	if app.state == "stopped" {
+		app.state = "started"
+		commands = append(commands, app.stateCommand(app.state))
+	}
+	return commands
+}
+
+func (app *AppStatus) scaleDown(cliConnection plugin.CliConnection, target int) error {
+	if !app.needsScaleDown(target) {
+		return nil
+	}
+	return app.issue(cliConnection, app.scaleDownCommands(target))
+}
+
+//issue runs cf commands against the app in order, reporting each as an event
+//and stopping at the first that fails
+func (app *AppStatus) issue(cliConnection plugin.CliConnection, commands [][]string) error {
+	for _, args := range commands {
+		if err := app.runCommand(cliConnection, args); err != nil {
+			return err
+		}
+		name := EventScale
+		if isRouteCommand(args) {
+			name = EventRoute
+		}
+		app.events.emit(Event{Event: name, App: newAppEvent(app), Command: args})
+	}
+	return nil
+}
+
+//scaleDownCommands updates the app to target instances and returns the cf commands that do it
+func (app *AppStatus) scaleDownCommands(target int) [][]string {
+	var commands [][]string
+	app.countRequested = target
+
+	// If going to zero, stop the app, and force to one instance
+	if app.countRequested <= 0 {
+		app.countRequested = 1
+		app.state = "stopped"
+		commands = append(commands, app.stateCommand(app.state))
+	}
+
+	return append(commands, app.scaleCommand(app.countRequested))
+}
+
+//restore scales the app back to the count and state captured in orig
+func (app *AppStatus) restore(cliConnection plugin.CliConnection, orig AppStatus) error {
+	if orig.state == "stopped" {
+		wasStopped := app.state == "stopped"
+		app.state = orig.state
+		app.countRequested = orig.countRequested
+		if wasStopped {
+			return nil
+		}
+		commands := [][]string{app.stateCommand(app.state)}
+		if orig.stoppedCount > 0 {
+			commands = append(commands, app.scaleCommand(orig.stoppedCount))
+		}
+		return app.issue(cliConnection, commands)
+	}
+
+	return app.issue(cliConnection, app.scaleUpCommands(orig.countRequested))
+}
+
+func (cmd *Scaleover) showStatus() {
+	if cmd.events.toOut() {
+		return
+	}
+	if cmd.OnStatus != nil {
+		cmd.OnStatus(newGroupEvent(cmd.sources), newGroupEvent(cmd.targets))
+	} else {
+		fmt.Fprintln(cmd.out(), statusLine(cmd.sources, cmd.targets))
+	}
+}
+
+func statusLine(sources []*AppStatus, targets []*AppStatus) string {
+	return fmt.Sprintf("%s (%s) %d instances, %s (%s) %d instances",
+		names(sources),
+		state(sources),
+		requested(sources),
+		names(targets),
+		state(targets),
+		requested(targets),
+	)
+}
+
+//appsShareARoute is true when one route is mapped to every source and target
+func (cmd *Scaleover) appsShareARoute() bool {
+	apps := cmd.apps()
+	for _, r := range apps[0].routes {
+		shared := true
+		for _, app := range apps[1:] {
+			shared = shared && app.hasRoute(r)
+		}
+		if shared {
+			return true
+		}
+	}
+	return false
+}
+
+//errorIfNoSharedRoute makes do with a shared domain for apps in different spaces, which can't share a route
+func (cmd *Scaleover) errorIfNoSharedRoute() error {
+	if cmd.appsShareARoute() {
+		return nil
+	}
+	if !cmd.inOneSpace() {
+		if cmd.appsShareADomain() {
+			return nil
+		}
+		return errors.New("Apps do not share a domain!")
+	}
+	if mismatch := cmd.wildcardMismatch(); mismatch != "" {
+		return fmt.Errorf("Apps do not share a route! %s", mismatch)
+	}
+	return errors.New("Apps do not share a route!")
+}
+
+//wildcardMismatch describes the first route one app has as a wildcard and another as a specific host
+func (cmd *Scaleover) wildcardMismatch() string {
+	apps := cmd.apps()
+	for _, app := range apps {
+		for _, r := range app.routes {
+			for _, other := range apps {
+				if near, found := other.wildcardRoute(r); found {
+					return fmt.Sprintf("%s has %s but %s has %s, which isn't the same route", app.name, r, other.name, near)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+//errorIfMissingRoutes lists each required route that isn't mapped to each app.
+//The route --map-route will map to the targets doesn't need to be there yet.
+func (cmd *Scaleover) errorIfMissingRoutes(required []route) error {
+	var missing []string
+	for _, r := range required {
+		for _, app := range cmd.sources {
+			if !app.hasRoute(r) {
+				missing = append(missing, notMapped(r, app))
+			}
+		}
+		for _, app := range cmd.targets {
+			if !app.hasRoute(r) && (cmd.route == nil || !cmd.route.same(r)) {
+				missing = append(missing, notMapped(r, app))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Required routes are missing:\n\t%s", strings.Join(missing, "\n\t"))
+	}
+	return nil
+}
+
+//notMapped points out a wildcard standing in for the required route, or the other way around
+func notMapped(r route, app *AppStatus) string {
+	if near, found := app.wildcardRoute(r); found {
+		return fmt.Sprintf("%s is not mapped to %s, it has %s instead", r, app.name, near)
+	}
+	return fmt.Sprintf("%s is not mapped to %s", r, app.name)
+}
@@ -0,0 +1,479 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/models"
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scaleover", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var status *AppStatus
+	domain := plugin_models.GetApp_DomainFields{Name: "cfapps.io"}
+
+	Describe("getAppStatus", func() {
+
+		BeforeEach(func() {
+			fakeCliConnection = &pluginfakes.FakeCliConnection{}
+			scaleoverCmdPlugin = &Scaleover{}
+		})
+
+		It("should Fail Without App 1", func() {
+			app := plugin_models.GetAppModel{}
+			fakeCliConnection.GetAppReturns(app, errors.New("App app1 not found"))
+			//the command above shuld return nil through a cli fake?
+			_, err := scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+			Expect(err.Error()).To(Equal("App app1 not found"))
+		})
+
+		It("should not start a stopped target with 1 instance", func() {
+			app := plugin_models.GetAppModel{State: "stopped"}
+			fakeCliConnection.GetAppReturns(app, nil)
+
+			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+
+			Expect(status.name).To(Equal("app1"))
+			Expect(status.countRequested).To(Equal(0))
+			Expect(status.countRunning).To(Equal(0))
+			Expect(status.state).To(Equal("stopped"))
+		})
+
+		It("should report the correct number of instances", func() {
+			app := plugin_models.GetAppModel{
+				InstanceCount:    10,
+				RunningInstances: 10,
+				State:            "started",
+			}
+			fakeCliConnection.GetAppReturns(app, nil)
+
+			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+
+			Expect(status.name).To(Equal("app1"))
+			Expect(status.countRequested).To(Equal(10))
+			Expect(status.countRunning).To(Equal(10))
+		})
+
+		It("should keep a stoped app stopped with 10 instances", func() {
+
+			app := plugin_models.GetAppModel{
+				InstanceCount:    10,
+				RunningInstances: 0,
+				State:            "stopped",
+			}
+			fakeCliConnection.GetAppReturns(app, nil)
+
+			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+
+			Expect(status.name).To(Equal("app1"))
+			Expect(status.countRequested).To(Equal(0))
+			Expect(status.countRunning).To(Equal(0))
+			Expect(status.state).To(Equal("stopped"))
+		})
+
+		It("should report zero requested instances for a stopped app", func() {
+			app := plugin_models.GetAppModel{
+				InstanceCount:    10,
+				RunningInstances: 10,
+				State:            "stopped",
+			}
+
+			fakeCliConnection.GetAppReturns(app, nil)
+
+			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+			Ω(status.countRequested).To(Equal(0))
+		})
+
+		It("should populate the routes for an app with one url", func() {
+			routes := []plugin_models.GetApp_RouteSummary{
+				{
+					Host:   "app",
+					Domain: domain,
+				},
+			}
+
+			app := plugin_models.GetAppModel{Routes: routes}
+			fakeCliConnection.GetAppReturns(app, nil)
+			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+			Expect(len(status.routes)).To(Equal(1))
+			Expect(status.routes[0].String()).To(Equal("app.cfapps.io"))
+		})
+
+		It("should populate the routes for an app with three urls", func() {
+
+			routes := []plugin_models.GetApp_RouteSummary{
+				{
+					Host:   "app",
+					Domain: domain,
+				},
+				{
+					Host:   "foo-app",
+					Domain: domain,
+				},
+				{
+					Host:   "foo-app-b",
+					Domain: domain,
+				},
+			}
+
+			app := plugin_models.GetAppModel{Routes: routes}
+			fakeCliConnection.GetAppReturns(app, nil)
+
+			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+			Expect(len(status.routes)).To(Equal(3))
+			Expect(status.routes[0].String()).To(Equal("app.cfapps.io"))
+			Expect(status.routes[1].String()).To(Equal("foo-app.cfapps.io"))
+			Expect(status.routes[2].String()).To(Equal("foo-app-b.cfapps.io"))
+		})
+
+	})
+
+	Describe("scale up", func() {
+		var appStatus *AppStatus
+
+		BeforeEach(func() {
+			appStatus = &AppStatus{
+				name:           "foo",
+				countRequested: 1,
+				countRunning:   1,
+				state:          "stopped",
+			}
+			fakeCliConnection = &pluginfakes.FakeCliConnection{}
+
+		})
+
+		It("Starts a stopped app", func() {
+			appStatus.scaleUp(fakeCliConnection, 1)
+			Expect(appStatus.state).To(Equal("started"))
+		})
+
+		It("It increments the amount requested", func() {
+			running := appStatus.countRunning
+			appStatus.scaleUp(fakeCliConnection, running+1)
+			Expect(appStatus.countRequested).To(Equal(running + 1))
+		})
+
+		It("Leaves a started app started", func() {
+			appStatus.state = "started"
+			appStatus.scaleUp(fakeCliConnection, 1)
+			Expect(appStatus.state).To(Equal("started"))
+		})
+
+	})
+
+	Describe("scale down", func() {
+		var appStatus *AppStatus
+		var appStatus2 *AppStatus
+
+		BeforeEach(func() {
+			appStatus = &AppStatus{
+				name:           "foo",
+				countRequested: 1,
+				countRunning:   1,
+				state:          "started",
+			}
+
+			appStatus2 = &AppStatus{
+				name:           "bar",
+				countRequested: 3,
+				countRunning:   3,
+				state:          "started",
+			}
+			fakeCliConnection = &pluginfakes.FakeCliConnection{}
+
+		})
+
+		It("Stops a started app going to zero instances", func() {
+			appStatus.scaleDown(fakeCliConnection, 0)
+			Expect(appStatus.state).To(Equal("stopped"))
+		})
+
+		It("It decrements the amount requested", func() {
+			running := appStatus2.countRunning
+			appStatus2.scaleDown(fakeCliConnection, running-1)
+			Expect(appStatus2.countRequested).To(Equal(running - 1))
+			Expect(appStatus2.state).To(Equal("started"))
+		})
+
+		It("It decrements the batch-size 1 but leaves 1 stopped", func() {
+			appStatus.scaleDown(fakeCliConnection, 0)
+			Expect(appStatus.countRequested).To(Equal(1)) //
+			Expect(appStatus.state).To(Equal("stopped"))
+		})
+
+		It("It decrements the batch-size requested but leaves 1 stopped", func() {
+			running := appStatus2.countRunning
+			batchSize := 2
+			appStatus2.scaleDown(fakeCliConnection, running-batchSize)
+			Expect(appStatus2.countRequested).To(Equal(running - batchSize)) //
+			Expect(appStatus2.state).To(Equal("started"))
+		})
+
+		It("Leaves 1 instance stopped", func() {
+			appStatus.countRequested = 1
+			appStatus.countRunning = 1
+			appStatus.scaleDown(fakeCliConnection, 0)
+			Expect(appStatus.countRequested).To(Equal(1))
+			Expect(appStatus.state).To(Equal("stopped"))
+		})
+
+		It("Leaves a stopped app stopped", func() {
+			appStatus.state = "stopped"
+			appStatus.scaleDown(fakeCliConnection, 0)
+			Expect(appStatus.state).To(Equal("stopped"))
+		})
+
+		It("Scales down the app", func() {
+			appStatus.countRequested = 2
+			appStatus.scaleDown(fakeCliConnection, 1)
+			Expect(appStatus.countRunning).To(Equal(1))
+			Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(1))
+		})
+
+	})
+
+	Describe("Routes", func() {
+		BeforeEach(func() {
+			scaleoverCmdPlugin = &Scaleover{}
+			var app1 = &AppStatus{
+				routes: []route{{host: "a", domain: "b.c"}, {host: "b", domain: "c.d"}},
+			}
+			var app2 = &AppStatus{
+				routes: []route{{host: "c", domain: "d.e"}, {host: "d", domain: "e.f"}},
+			}
+			scaleoverCmdPlugin.sources = []*AppStatus{app1}
+			scaleoverCmdPlugin.targets = []*AppStatus{app2}
+		})
+
+		It("should return false if the apps don't share a route", func() {
+			Expect(scaleoverCmdPlugin.appsShareARoute()).To(BeFalse())
+		})
+
+		It("should return true when they share a route", func() {
+			scaleoverCmdPlugin.targets = scaleoverCmdPlugin.sources
+			Expect(scaleoverCmdPlugin.appsShareARoute()).To(BeTrue())
+		})
+
+		It("Should warn when apps don't share a route", func() {
+			Expect(scaleoverCmdPlugin.errorIfNoSharedRoute().Error()).To(Equal("Apps do not share a route!"))
+		})
+
+		It("Should say when a wildcard is all that stands in for a shared route", func() {
+			scaleoverCmdPlugin.sources[0].name, scaleoverCmdPlugin.targets[0].name = "blue", "green"
+			scaleoverCmdPlugin.targets[0].routes = append(scaleoverCmdPlugin.targets[0].routes, route{host: "*", domain: "b.c"})
+			Expect(scaleoverCmdPlugin.errorIfNoSharedRoute().Error()).To(Equal("Apps do not share a route! " +
+				"blue has a.b.c but green has *.b.c, which isn't the same route"))
+		})
+
+		It("Should be just fine if apps share a route", func() {
+			scaleoverCmdPlugin.targets[0].routes = append(scaleoverCmdPlugin.targets[0].routes, route{host: "a", domain: "b.c"})
+			Expect(scaleoverCmdPlugin.errorIfNoSharedRoute()).To(BeNil())
+		})
+
+		It("should not count a route with a different path as shared", func() {
+			scaleoverCmdPlugin.targets[0].routes = append(scaleoverCmdPlugin.targets[0].routes, route{host: "a", domain: "b.c", path: "/admin"})
+			Expect(scaleoverCmdPlugin.appsShareARoute()).To(BeFalse())
+		})
+
+		It("should not count a route on a different port as shared", func() {
+			scaleoverCmdPlugin.sources[0].routes = []route{{domain: "tcp.b.c", port: 1024}}
+			scaleoverCmdPlugin.targets[0].routes = []route{{domain: "tcp.b.c", port: 1025}}
+			Expect(scaleoverCmdPlugin.appsShareARoute()).To(BeFalse())
+		})
+
+		It("should read paths, ports and empty hosts from the app", func() {
+			fakeCliConnection := &pluginfakes.FakeCliConnection{}
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{Routes: []plugin_models.GetApp_RouteSummary{
+				{Domain: plugin_models.GetApp_DomainFields{Name: "cfapps.io"}},
+				{Host: "app", Domain: plugin_models.GetApp_DomainFields{Name: "cfapps.io"}, Path: "/api"},
+				{Domain: plugin_models.GetApp_DomainFields{Name: "tcp.cfapps.io"}, Port: 1024},
+			}}, nil)
+			status, _ := scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+			Expect(status.routes).To(Equal([]route{
+				{domain: "cfapps.io"},
+				{host: "app", domain: "cfapps.io", path: "/api"},
+				{domain: "tcp.cfapps.io", port: 1024},
+			}))
+		})
+	})
+
+	Describe("Do Scaleover", func() {
+		BeforeEach(func() {
+			scaleoverCmdPlugin = &Scaleover{}
+			var app1 = &AppStatus{
+				countRunning:   10,
+				countRequested: 10,
+				state:          "started",
+			}
+			var app2 = &AppStatus{
+				countRunning:   0,
+				countRequested: 0,
+				state:          "stopped",
+			}
+			scaleoverCmdPlugin.sources = []*AppStatus{app1}
+			scaleoverCmdPlugin.targets = []*AppStatus{app2}
+		})
+
+		It("should scale app2 to 10", func() {
+			scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1})
+			Ω(scaleoverCmdPlugin.targets[0].countRequested).To(Equal(10))
+			Ω(scaleoverCmdPlugin.sources[0].countRequested).To(Equal(1))
+			Ω(scaleoverCmdPlugin.sources[0].state).To(Equal("stopped"))
+		})
+
+		It("should scale app2 to 10 and app1 down to N if a <leave N> is specified", func() {
+			scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{Leave: 1, BatchSize: 1})
+			Ω(scaleoverCmdPlugin.targets[0].countRequested).To(Equal(10))
+			Ω(scaleoverCmdPlugin.sources[0].countRequested).To(Equal(1))
+			Ω(scaleoverCmdPlugin.sources[0].state).To(Equal("started"))
+		})
+
+		It("should stop scaling when the new instances crash", func() {
+			fakeCliConnection = &pluginfakes.FakeCliConnection{}
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{
+				Instances: []plugin_models.GetApp_AppInstanceFields{{State: "crashed"}},
+			}, nil)
+			err := scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1, WaitForStarted: true})
+			Ω(err).To(HaveOccurred())
+			Ω(scaleoverCmdPlugin.targets[0].countRequested).To(Equal(1))
+			Ω(scaleoverCmdPlugin.sources[0].countRequested).To(Equal(10))
+		})
+	})
+
+	Describe("Rollback", func() {
+		BeforeEach(func() {
+			fakeCliConnection = &pluginfakes.FakeCliConnection{}
+			scaleoverCmdPlugin = &Scaleover{
+				sources: []*AppStatus{{name: "blue", countRequested: 6, state: "started"}},
+				targets: []*AppStatus{{name: "green", countRequested: 4, state: "started"}},
+			}
+			scaleoverCmdPlugin.origSources = []AppStatus{{name: "blue", countRequested: 10, state: "started"}}
+			scaleoverCmdPlugin.origTargets = []AppStatus{{name: "green", countRequested: 0, state: "stopped"}}
+		})
+
+		It("should restore both apps to their original counts and states", func() {
+			scaleoverCmdPlugin.rollback(fakeCliConnection)
+			Ω(scaleoverCmdPlugin.sources[0].countRequested).To(Equal(10))
+			Ω(scaleoverCmdPlugin.sources[0].state).To(Equal("started"))
+			Ω(scaleoverCmdPlugin.targets[0].countRequested).To(Equal(0))
+			Ω(scaleoverCmdPlugin.targets[0].state).To(Equal("stopped"))
+		})
+
+		It("should scale the source back up before stopping the target", func() {
+			scaleoverCmdPlugin.rollback(fakeCliConnection)
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(2))
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(0)).To(Equal([]string{"scale", "-i", "10", "blue"}))
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(1)).To(Equal([]string{"stop", "green"}))
+		})
+
+		It("should scale a stopped target back to the instances it had", func() {
+			scaleoverCmdPlugin.origTargets[0].stoppedCount = 2
+			scaleoverCmdPlugin.rollback(fakeCliConnection)
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(1)).To(Equal([]string{"stop", "green"}))
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(2)).To(Equal([]string{"scale", "-i", "2", "green"}))
+			Ω(scaleoverCmdPlugin.targets[0].countRequested).To(Equal(0))
+		})
+
+		It("should restart a source that had been stopped", func() {
+			scaleoverCmdPlugin.sources[0] = &AppStatus{name: "blue", countRequested: 1, state: "stopped"}
+			scaleoverCmdPlugin.rollback(fakeCliConnection)
+			Ω(scaleoverCmdPlugin.sources[0].state).To(Equal("started"))
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(1)).To(Equal([]string{"start", "blue"}))
+		})
+	})
+
+	Describe("Run", func() {
+		BeforeEach(func() {
+			fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		})
+
+		It("returns the error that stopped it and hands every event to OnEvent", func() {
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{}, errors.New("App blue not found"))
+			var events []Event
+			scaleoverCmdPlugin = &Scaleover{OnEvent: func(e Event) { events = append(events, e) }}
+
+			err := scaleoverCmdPlugin.Run(fakeCliConnection, []string{"blue"}, []string{"green"}, []int{1}, time.Minute, DefaultOptions())
+			Ω(err).Should(MatchError("App blue not found"))
+			Ω(events).Should(HaveLen(1))
+			Ω(events[0].Event).Should(Equal(EventFailed))
+			Ω(events[0].Error).Should(Equal("App blue not found"))
+		})
+
+		It("finishes without doing anything when the sources have no instances", func() {
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{State: "stopped", InstanceCount: 1,
+				Routes: []plugin_models.GetApp_RouteSummary{{Host: "www", Domain: domain}}}, nil)
+			scaleoverCmdPlugin = &Scaleover{}
+
+			Ω(scaleoverCmdPlugin.Run(fakeCliConnection, []string{"blue"}, []string{"green"}, []int{1}, time.Minute, DefaultOptions())).Should(Succeed())
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).Should(Equal(0))
+		})
+
+		It("won't leave more instances than the sources have", func() {
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{State: "started", InstanceCount: 2,
+				Routes: []plugin_models.GetApp_RouteSummary{{Host: "www", Domain: domain}}}, nil)
+			scaleoverCmdPlugin = &Scaleover{}
+			opts := DefaultOptions()
+			opts.Leave = 3
+
+			err := scaleoverCmdPlugin.Run(fakeCliConnection, []string{"blue"}, []string{"green"}, []int{1}, time.Minute, opts)
+			Ω(err).Should(MatchError("Can't leave 3 instances of blue, it only has 2"))
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).Should(Equal(0))
+		})
+
+		It("only reads from the Cloud Controller for --dry-run", func() {
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{State: "started", InstanceCount: 2,
+				Routes: []plugin_models.GetApp_RouteSummary{{Host: "www", Domain: domain}}}, nil)
+			fakeCliConnection.GetOrgReturns(plugin_models.GetOrg_Model{
+				Guid:            "org-guid",
+				QuotaDefinition: plugin_models.QuotaFields{Guid: "org-quota", Name: "default", MemoryLimit: 1024},
+			}, nil)
+			fakeCliConnection.CliCommandWithoutTerminalOutputReturns([]string{`{"memory_usage_in_mb": 0}`}, nil)
+			scaleoverCmdPlugin = &Scaleover{}
+			opts := DefaultOptions()
+			opts.DryRun = true
+
+			Ω(scaleoverCmdPlugin.Run(fakeCliConnection, []string{"blue"}, []string{"green"}, []int{1}, time.Minute, opts)).Should(Succeed())
+			Ω(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).Should(BeNumerically(">", 0))
+			for i := 0; i < fakeCliConnection.CliCommandWithoutTerminalOutputCallCount(); i++ {
+				args := fakeCliConnection.CliCommandWithoutTerminalOutputArgsForCall(i)
+				Ω(args).Should(HaveLen(2), "cf %v isn't a plain GET", args)
+				Ω(args[0]).Should(Equal("curl"))
+			}
+			Ω(fakeCliConnection.CliCommandCallCount()).Should(Equal(0))
+		})
+
+		It("starts afresh each time it's run", func() {
+			fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{State: "stopped", InstanceCount: 1,
+				Routes: []plugin_models.GetApp_RouteSummary{{Host: "www", Domain: domain}}}, nil)
+			scaleoverCmdPlugin = &Scaleover{}
+
+			for i := 0; i < 2; i++ {
+				Ω(scaleoverCmdPlugin.Run(fakeCliConnection, []string{"blue"}, []string{"green"}, []int{1}, time.Minute, DefaultOptions())).Should(Succeed())
+				Ω(scaleoverCmdPlugin.apps()).Should(HaveLen(2))
+			}
+		})
+
+		It("returns the error when there's no scaleover to resume", func() {
+			scaleoverCmdPlugin = &Scaleover{}
+			Ω(scaleoverCmdPlugin.Resume(fakeCliConnection, "/nonexistent/scaleover-state.json")).ShouldNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,293 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+//v2Resources is a page of Cloud Controller v2 results, only the guids are needed
+type v2Resources struct {
+	Resources []struct {
+		Metadata struct {
+			GUID string `json:"guid"`
+		} `json:"metadata"`
+	} `json:"resources"`
+}
+
+//v2AppSummary is what /v2/apps/:guid/summary says about an app
+type v2AppSummary struct {
+	GUID             string `json:"guid"`
+	SpaceGUID        string `json:"space_guid"`
+	State            string `json:"state"`
+	Instances        int    `json:"instances"`
+	RunningInstances int    `json:"running_instances"`
+	Memory           int64  `json:"memory"`
+	Routes           []struct {
+		GUID   string `json:"guid"`
+		Host   string `json:"host"`
+		Path   string `json:"path"`
+		Port   int    `json:"port"`
+		Domain struct {
+			Name string `json:"name"`
+		} `json:"domain"`
+	} `json:"routes"`
+}
+
+//v2Error is the body the Cloud Controller answers a failed request with
+type v2Error struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+	ErrorCode   string `json:"error_code"`
+}
+
+//splitAppID splits ORG/SPACE/APP, org and space are empty for an app in the targeted space
+func splitAppID(id string) (org string, space string, name string) {
+	parts := strings.SplitN(id, "/", 3)
+	if len(parts) < 3 {
+		return "", "", id
+	}
+	return parts[0], parts[1], parts[2]
+}
+
+//getQualifiedAppStatus looks up ORG/SPACE/APP through the Cloud Controller, wherever the CLI is targeted
+func (cmd *Scaleover) getQualifiedAppStatus(cliConnection plugin.CliConnection, id string) (*AppStatus, error) {
+	org, space, name := splitAppID(id)
+
+	orgGUID, err := findGUID(cliConnection, "/v2/organizations?q="+url.QueryEscape("name:"+org))
+	if err != nil {
+		return nil, err
+	}
+	if orgGUID == "" {
+		return nil, fmt.Errorf("Organization %s not found", org)
+	}
+	spaceGUID, err := findGUID(cliConnection, "/v2/organizations/"+orgGUID+"/spaces?q="+url.QueryEscape("name:"+space))
+	if err != nil {
+		return nil, err
+	}
+	if spaceGUID == "" {
+		return nil, fmt.Errorf("Space %s not found in organization %s", space, org)
+	}
+	appGUID, err := findGUID(cliConnection, "/v2/spaces/"+spaceGUID+"/apps?q="+url.QueryEscape("name:"+name))
+	if err != nil {
+		return nil, err
+	}
+	if appGUID == "" {
+		return nil, fmt.Errorf("App %s not found in %s/%s", name, org, space)
+	}
+
+	var summary v2AppSummary
+	if err = cfCurl(cliConnection, "/v2/apps/"+appGUID+"/summary", &summary); err != nil {
+		return nil, err
+	}
+
+	status := &AppStatus{
+		name:         id,
+		guid:         appGUID,
+		spaceGUID:    spaceGUID,
+		countRunning: summary.RunningInstances,
+		state:        strings.ToLower(summary.State),
+		routes:       make([]route, len(summary.Routes)),
+		memory:       summary.Memory,
+		events:       cmd.events,
+		out:          cmd.out(),
+		client:       cmd.client,
+		clock:        cmd.Clock,
+	}
+	if status.state != "stopped" {
+		status.countRequested = summary.Instances
+	} else {
+		status.stoppedCount = summary.Instances
+	}
+	for idx, r := range summary.Routes {
+		status.routes[idx] = route{host: r.Host, domain: r.Domain.Name, path: r.Path, port: r.Port, guid: r.GUID}
+	}
+	return status, nil
+}
+
+//findGUID is the guid of the first resource a v2 query finds, or empty when there isn't one
+func findGUID(cliConnection plugin.CliConnection, path string) (string, error) {
+	var page v2Resources
+	if err := cfCurl(cliConnection, path, &page); err != nil {
+		return "", err
+	}
+	if len(page.Resources) == 0 {
+		return "", nil
+	}
+	return page.Resources[0].Metadata.GUID, nil
+}
+
+//instanceStates is the state of each of the app's instances
+func (app *AppStatus) instanceStates(cliConnection plugin.CliConnection) ([]string, error) {
+	// apps in the targeted space can still be read by name without a client
+	var states []string
+	if app.guid == "" || (app.client == nil && !app.qualified()) {
+		refreshedStatus, err := cliConnection.GetApp(app.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range refreshedStatus.Instances {
+			states = append(states, instance.State)
+		}
+		return states, nil
+	}
+
+	var stats processStats
+	var err error
+	if app.client != nil {
+		stats, err = app.client.stats(app.guid)
+	} else {
+		err = cfCurl(cliConnection, statsPath(app.guid), &stats)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(stats.Resources, func(i, j int) bool { return stats.Resources[i].Index < stats.Resources[j].Index })
+	for _, instance := range stats.Resources {
+		states = append(states, instance.State)
+	}
+	return states, nil
+}
+
+//scaleCommand sets the app's instance count through the v3 API when its GUID is known
+func (app *AppStatus) scaleCommand(instances int) []string {
+	if app.guid != "" {
+		return []string{"curl", scalePath(app.guid), "-X", "POST", "-d", `{"instances":` + strconv.Itoa(instances) + `}`}
+	}
+	return []string{"scale", "-i", strconv.Itoa(instances), app.name}
+}
+
+//stateCommand starts or stops the app, through the v3 API when its GUID is known
+func (app *AppStatus) stateCommand(state string) []string {
+	action := "start"
+	if state == "stopped" {
+		action = "stop"
+	}
+	if app.guid != "" {
+		return []string{"curl", actionPath(app.guid, action), "-X", "POST"}
+	}
+	return []string{action, app.name}
+}
+
+//routeCommand is cf map-route or cf unmap-route, or the same by GUID for apps in another space.
+//checkRoutes only looks up the route's GUID for those, so without one the app is in the targeted space.
+func (app *AppStatus) routeCommand(command string, r route) []string {
+	if app.guid == "" || r.guid == "" {
+		return r.args(command, app.name)
+	}
+	method := "PUT"
+	if command == "unmap-route" {
+		method = "DELETE"
+	}
+	return []string{"curl", "/v2/routes/" + r.guid + "/apps/" + app.guid, "-X", method}
+}
+
+func isRouteCommand(args []string) bool {
+	return strings.HasSuffix(args[0], "-route") ||
+		(args[0] == "curl" && len(args) > 1 && strings.HasPrefix(args[1], "/v2/routes/"))
+}
+
+//curlError is the Cloud Controller's error when cf curl printed one, cf curl itself doesn't fail
+func curlError(output []string) error {
+	body := newAPIError("", "", 0, []byte(strings.Join(output, "\n")))
+	if body.Title == "" {
+		return nil
+	}
+	return errors.New(strings.Join(body.Details, ", "))
+}
+
+//findRouteGUID looks up the route's guid, needed to map it to apps in another space
+func findRouteGUID(cliConnection plugin.CliConnection, r route) (string, error) {
+	domainGUID, err := findGUID(cliConnection, "/v2/domains?q="+url.QueryEscape("name:"+r.domain))
+	if err != nil {
+		return "", err
+	}
+	if domainGUID == "" {
+		return "", fmt.Errorf("Domain %s not found", r.domain)
+	}
+
+	query := "/v2/routes?q=" + url.QueryEscape("domain_guid:"+domainGUID)
+	if r.protocol() == protocolTCP {
+		query += "&q=" + url.QueryEscape("port:"+strconv.Itoa(r.port))
+	} else {
+		query += "&q=" + url.QueryEscape("host:"+r.host)
+		if r.path != "" {
+			query += "&q=" + url.QueryEscape("path:"+r.path)
+		}
+	}
+	guid, err := findGUID(cliConnection, query)
+	if err == nil && guid == "" {
+		err = fmt.Errorf("Route %s not found", r)
+	}
+	return guid, err
+}
+
+//inOneSpace is true when every app is in the same space
+func (cmd *Scaleover) inOneSpace() bool {
+	apps := cmd.apps()
+	for _, app := range apps {
+		if app.spaceGUID != apps[0].spaceGUID {
+			return false
+		}
+	}
+	return true
+}
+
+//qualified is true when any app was named ORG/SPACE/APP
+func (cmd *Scaleover) qualified() bool {
+	for _, app := range cmd.apps() {
+		if app.qualified() {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *AppStatus) qualified() bool {
+	org, _, _ := splitAppID(app.name)
+	return org != ""
+}
+
+//appsShareADomain is true when one domain has routes to every app, which is as close as
+//apps in different spaces can get to sharing a route
+func (cmd *Scaleover) appsShareADomain() bool {
+	apps := cmd.apps()
+	for _, r := range apps[0].routes {
+		shared := true
+		for _, app := range apps[1:] {
+			shared = shared && app.hasDomain(r.domain)
+		}
+		if shared {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *AppStatus) hasDomain(domain string) bool {
+	for _, mapped := range app.routes {
+		if strings.EqualFold(mapped.domain, domain) {
+			return true
+		}
+	}
+	return false
+}
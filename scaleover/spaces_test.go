@@ -0,0 +1,114 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Apps in other spaces", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var responses map[string]string
+
+	BeforeEach(func() {
+		responses = map[string]string{
+			"/v2/organizations?q=name%3Aacme":                    `{"resources": [{"metadata": {"guid": "org-guid"}}]}`,
+			"/v2/organizations/org-guid/spaces?q=name%3Aprod-v2": `{"resources": [{"metadata": {"guid": "space-guid"}}]}`,
+			"/v2/spaces/space-guid/apps?q=name%3Anode":           `{"resources": [{"metadata": {"guid": "app-guid"}}]}`,
+			"/v2/apps/app-guid/summary": `{"guid": "app-guid", "state": "STARTED", "instances": 4, "running_instances": 3, "memory": 256,
+				"routes": [{"guid": "route-guid", "host": "node", "domain": {"name": "cfapps.io"}}]}`,
+			"/v3/apps/app-guid/processes/web/stats": `{"resources": [{"type": "web", "index": 1, "state": "STARTING"}, {"type": "web", "index": 0, "state": "RUNNING"}]}`,
+		}
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		fakeCliConnection.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
+			if response, ok := responses[args[1]]; ok && len(args) == 2 {
+				return []string{response}, nil
+			}
+			return []string{`{"resources": []}`}, nil
+		}
+		scaleoverCmdPlugin = &Scaleover{}
+	})
+
+	It("looks up ORG/SPACE/APP by GUID", func() {
+		status, err := scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "acme/prod-v2/node")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(status.name).Should(Equal("acme/prod-v2/node"))
+		Ω(status.guid).Should(Equal("app-guid"))
+		Ω(status.spaceGUID).Should(Equal("space-guid"))
+		Ω(status.state).Should(Equal("started"))
+		Ω(status.countRequested).Should(Equal(4))
+		Ω(status.countRunning).Should(Equal(3))
+		Ω(status.memory).Should(Equal(int64(256)))
+		Ω(status.routes).Should(Equal([]route{{host: "node", domain: "cfapps.io", guid: "route-guid"}}))
+	})
+
+	It("says which part of the name wasn't found", func() {
+		_, err := scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "acme/prod-v3/node")
+		Ω(err).Should(MatchError("Space prod-v3 not found in organization acme"))
+	})
+
+	It("scales, starts and stops by GUID", func() {
+		app := &AppStatus{name: "acme/prod-v2/node", guid: "app-guid", state: "stopped"}
+		Ω(app.scaleUpCommands(2)).Should(Equal([][]string{
+			{"curl", "/v3/apps/app-guid/processes/web/actions/scale", "-X", "POST", "-d", `{"instances":2}`},
+			{"curl", "/v3/apps/app-guid/actions/start", "-X", "POST"},
+		}))
+		Ω(app.scaleDownCommands(0)).Should(Equal([][]string{
+			{"curl", "/v3/apps/app-guid/actions/stop", "-X", "POST"},
+			{"curl", "/v3/apps/app-guid/processes/web/actions/scale", "-X", "POST", "-d", `{"instances":1}`},
+		}))
+	})
+
+	It("maps routes by GUID", func() {
+		app := &AppStatus{name: "acme/prod-v2/node", guid: "app-guid"}
+		r := route{host: "www", domain: "cfapps.io", guid: "route-guid"}
+		Ω(app.mapRouteCommands(r)).Should(Equal([][]string{{"curl", "/v2/routes/route-guid/apps/app-guid", "-X", "PUT"}}))
+		Ω(app.unmapRouteCommands(r)).Should(Equal([][]string{{"curl", "/v2/routes/route-guid/apps/app-guid", "-X", "DELETE"}}))
+	})
+
+	It("maps routes by name when the route's GUID wasn't looked up", func() {
+		app := &AppStatus{name: "node", guid: "app-guid"}
+		r := route{host: "www", domain: "cfapps.io"}
+		Ω(app.mapRouteCommands(r)).Should(Equal([][]string{{"map-route", "node", "cfapps.io", "--hostname", "www"}}))
+	})
+
+	It("fails when the Cloud Controller answers with an error", func() {
+		fakeCliConnection.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
+			return []string{`{"code": 100005, "description": "You have exceeded your organization's memory limit.", "error_code": "CF-AppMemoryQuotaExceeded"}`}, nil
+		}
+		app := &AppStatus{name: "acme/prod-v2/node", guid: "app-guid", state: "started", countRequested: 1}
+		err := app.scaleUp(fakeCliConnection, 2)
+		Ω(err).Should(MatchError(`cf curl /v3/apps/app-guid/processes/web/actions/scale -X POST -d {"instances":2} failed: You have exceeded your organization's memory limit.`))
+	})
+
+	It("waits for the instances the Cloud Controller lists", func() {
+		app := &AppStatus{name: "acme/prod-v2/node", guid: "app-guid"}
+		Ω(app.instanceStates(fakeCliConnection)).Should(Equal([]string{"RUNNING", "STARTING"}))
+		Ω(app.waitForStart(fakeCliConnection, 0)).Should(MatchError("Timed out after 0s waiting for acme/prod-v2/node to start, 1 of 2 instances are running"))
+	})
+
+	It("settles for a shared domain when the apps are in different spaces", func() {
+		scaleoverCmdPlugin.sources = []*AppStatus{{name: "blue", spaceGUID: "space-1", routes: []route{{host: "blue", domain: "cfapps.io"}}}}
+		scaleoverCmdPlugin.targets = []*AppStatus{{name: "acme/prod-v2/green", guid: "app-guid", spaceGUID: "space-2",
+			routes: []route{{host: "green", domain: "cfapps.io"}}}}
+		Ω(scaleoverCmdPlugin.errorIfNoSharedRoute()).Should(Succeed())
+
+		scaleoverCmdPlugin.targets[0].routes = []route{{host: "green", domain: "example.com"}}
+		Ω(scaleoverCmdPlugin.errorIfNoSharedRoute()).Should(MatchError("Apps do not share a domain!"))
+	})
+})
@@ -0,0 +1,188 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+)
+
+//defaultStartTimeout is how long to wait for scaled up instances to report running
+const defaultStartTimeout = 5 * time.Minute
+
+//What to do when scaled up instances don't start
+const (
+	StartAbort    = "abort"
+	StartRollback = "rollback"
+	StartContinue = "continue"
+)
+
+//Ways scaled up instances can fail to start
+const (
+	StartTimedOut = "timeout"
+	StartCrashed  = "crash"
+	StartUnknown  = "error"
+)
+
+//StartFailurePolicy is what to do for each way the scaled up instances can fail to start.
+//Abort stops the scaleover where it is, so it can be resumed, rollback restores both apps,
+//and continue scales the sources down anyway. Empty is rollback with RollbackOnFailure, otherwise
+//continue when balancing by readiness, which only scales down by what did start, and abort.
+type StartFailurePolicy struct {
+	Timeout string `json:"timeout,omitempty"`
+	Crash   string `json:"crash,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+//outcome is what the policy does about failure, or fallback when it doesn't say
+func (policy StartFailurePolicy) outcome(failure string, fallback string) string {
+	var outcome string
+	switch failure {
+	case StartTimedOut:
+		outcome = policy.Timeout
+	case StartCrashed:
+		outcome = policy.Crash
+	case StartUnknown:
+		outcome = policy.Error
+	}
+	if outcome == "" {
+		return fallback
+	}
+	return outcome
+}
+
+//ParseStartFailurePolicy reads an outcome for every failure, like rollback, or outcomes
+//for some of them, like timeout=continue,crash=rollback, leaving the rest as they are in policy
+func ParseStartFailurePolicy(spec string, policy StartFailurePolicy) (StartFailurePolicy, error) {
+	for _, field := range strings.Split(spec, ",") {
+		failure, outcome := "", strings.TrimSpace(field)
+		if equals := strings.Index(outcome, "="); equals >= 0 {
+			failure, outcome = strings.TrimSpace(outcome[:equals]), strings.TrimSpace(outcome[equals+1:])
+		}
+		if outcome != StartAbort && outcome != StartRollback && outcome != StartContinue {
+			return policy, fmt.Errorf("A start failure can abort, rollback or continue, not %q", outcome)
+		}
+
+		switch failure {
+		case "":
+			policy = StartFailurePolicy{Timeout: outcome, Crash: outcome, Error: outcome}
+		case StartTimedOut:
+			policy.Timeout = outcome
+		case StartCrashed:
+			policy.Crash = outcome
+		case StartUnknown:
+			policy.Error = outcome
+		default:
+			return policy, fmt.Errorf("Start failures are timeout, crash or error, not %q", failure)
+		}
+	}
+	return policy, nil
+}
+
+//startError is a target that failed to start, and what's being done about it
+type startError struct {
+	failure string
+	outcome string
+	err     error
+}
+
+func (err *startError) Error() string {
+	return err.err.Error()
+}
+
+//waitForTargets waits for every target's instances to run, then for opts.PostStartSleep.
+//A target that fails to start stops the scaleover, unless opts.OnStartFailure says to continue.
+func (cmd *Scaleover) waitForTargets(cliConnection plugin.CliConnection, opts Options) error {
+	timeout := opts.StartTimeout
+	if timeout <= 0 {
+		timeout = defaultStartTimeout
+	}
+
+	fallback := StartAbort
+	switch {
+	case opts.RollbackOnFailure:
+		fallback = StartRollback
+	case opts.Balance == BalanceReady:
+		fallback = StartContinue
+	}
+
+	for _, app := range cmd.targets {
+		err := app.waitForStart(cliConnection, timeout)
+		if err == nil {
+			continue
+		}
+		failed, ok := err.(*startError)
+		if !ok {
+			return err
+		}
+
+		failed.outcome = opts.OnStartFailure.outcome(failed.failure, fallback)
+		if failed.outcome != StartContinue {
+			return failed
+		}
+		cmd.say("%s, continuing anyway\n", failed)
+		cmd.events.emit(Event{Event: EventStartFailed, App: newAppEvent(app), Error: failed.Error()})
+	}
+	orSystemClock(cmd.Clock).Sleep(opts.PostStartSleep)
+	return nil
+}
+
+//waitForStart polls the app until as many instances are running as it asked for. It fails
+//as soon as any are crashed, flapping or down, when they can't be checked, or after timeout.
+func (app *AppStatus) waitForStart(cliConnection plugin.CliConnection, timeout time.Duration) error {
+	clock := orSystemClock(app.clock)
+	deadline := clock.Now().Add(timeout)
+	lastRunning := app.countRunning
+	for {
+		states, err := app.instanceStates(cliConnection)
+		if nil != err {
+			return &startError{failure: StartUnknown,
+				err: fmt.Errorf("Can't tell whether %s started: %s", app.name, err)}
+		}
+
+		running, failed := 0, ""
+		for _, instanceState := range states {
+			switch state := strings.ToLower(instanceState); state {
+			case "running":
+				running++
+			case "crashed", "flapping", "down":
+				failed = state
+			}
+		}
+
+		// keep the running count current, it's what balancing by readiness goes on
+		app.countRunning = running
+		if running > lastRunning {
+			app.events.emit(Event{Event: EventRunning, App: newAppEvent(app)})
+		}
+		lastRunning = running
+
+		if failed != "" {
+			return &startError{failure: StartCrashed,
+				err: fmt.Errorf("%s has %s instances", app.name, failed)}
+		}
+		if running == len(states) && running >= app.countRequested {
+			return nil
+		}
+		if clock.Now().After(deadline) {
+			return &startError{failure: StartTimedOut, err: fmt.Errorf("Timed out after %s waiting for %s to start, %d of %d instances are running",
+				timeout, app.name, running, max(app.countRequested, len(states)))}
+		}
+		clock.Sleep(1 * time.Second)
+	}
+}
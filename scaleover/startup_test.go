@@ -0,0 +1,137 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/models"
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Waiting for targets to start", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var clock *fakeClock
+
+	instances := func(states ...string) plugin_models.GetAppModel {
+		var app plugin_models.GetAppModel
+		for _, state := range states {
+			app.Instances = append(app.Instances, plugin_models.GetApp_AppInstanceFields{State: state})
+		}
+		return app
+	}
+
+	BeforeEach(func() {
+		clock = newFakeClock()
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRequested: 3, countRunning: 3, state: "started", clock: clock}},
+			targets: []*AppStatus{{name: "green", countRequested: 2, state: "started", clock: clock}},
+			Clock:   clock,
+		}
+	})
+
+	It("waits until as many instances are running as were asked for", func() {
+		polls := 0
+		fakeCliConnection.GetAppStub = func(string) (plugin_models.GetAppModel, error) {
+			polls++
+			if polls < 3 {
+				return instances("running"), nil
+			}
+			return instances("running", "RUNNING"), nil
+		}
+		Ω(scaleoverCmdPlugin.targets[0].waitForStart(fakeCliConnection, time.Minute)).Should(Succeed())
+		Ω(polls).Should(Equal(3))
+	})
+
+	It("fails straight away on crashed, flapping or down instances", func() {
+		for _, state := range []string{"CRASHED", "flapping", "DOWN"} {
+			fakeCliConnection.GetAppReturns(instances("running", state), nil)
+			err := scaleoverCmdPlugin.targets[0].waitForStart(fakeCliConnection, time.Minute)
+			Ω(err).Should(BeAssignableToTypeOf(&startError{}))
+			Ω(err.(*startError).failure).Should(Equal(StartCrashed))
+		}
+		Ω(clock.waits).Should(BeEmpty())
+	})
+
+	It("fails when the instances can't be checked", func() {
+		fakeCliConnection.GetAppReturns(plugin_models.GetAppModel{}, errors.New("App green not found"))
+		err := scaleoverCmdPlugin.targets[0].waitForStart(fakeCliConnection, time.Minute)
+		Ω(err).Should(MatchError("Can't tell whether green started: App green not found"))
+		Ω(err.(*startError).failure).Should(Equal(StartUnknown))
+	})
+
+	It("gives up once the timeout has passed", func() {
+		fakeCliConnection.GetAppReturns(instances("running", "starting"), nil)
+		err := scaleoverCmdPlugin.targets[0].waitForStart(fakeCliConnection, 0)
+		Ω(err).Should(MatchError("Timed out after 0s waiting for green to start, 1 of 2 instances are running"))
+		Ω(err.(*startError).failure).Should(Equal(StartTimedOut))
+	})
+
+	It("aborts by default", func() {
+		fakeCliConnection.GetAppReturns(instances("running", "crashed"), nil)
+		err := scaleoverCmdPlugin.waitForTargets(fakeCliConnection, Options{})
+		Ω(err).Should(MatchError("green has crashed instances"))
+		Ω(err.(*startError).outcome).Should(Equal(StartAbort))
+	})
+
+	It("takes the outcome for each failure from the policy", func() {
+		fakeCliConnection.GetAppReturns(instances("running", "starting"), nil)
+		opts := Options{StartTimeout: time.Minute, OnStartFailure: StartFailurePolicy{Timeout: StartRollback, Crash: StartContinue}}
+		err := scaleoverCmdPlugin.waitForTargets(fakeCliConnection, opts)
+		Ω(err.(*startError).outcome).Should(Equal(StartRollback))
+		Ω(clock.elapsed()).Should(BeNumerically("~", time.Minute, time.Second))
+
+		var events []Event
+		scaleoverCmdPlugin.events = &eventLog{onEvent: func(e Event) { events = append(events, e) }}
+		fakeCliConnection.GetAppReturns(instances("running", "crashed"), nil)
+		Ω(scaleoverCmdPlugin.waitForTargets(fakeCliConnection, opts)).Should(Succeed())
+		Ω(events).Should(HaveLen(1))
+		Ω(events[0].Event).Should(Equal(EventStartFailed))
+		Ω(events[0].Error).Should(Equal("green has crashed instances"))
+	})
+
+	It("rolls back or holds the scaleover as the policy says", func() {
+		fakeCliConnection.GetAppReturns(instances("crashed"), nil)
+		opts := Options{BatchSize: 1, WaitForStarted: true, OnStartFailure: StartFailurePolicy{Crash: StartRollback}}
+		scaleoverCmdPlugin.targets[0] = &AppStatus{name: "green", state: "stopped", weight: 1, clock: clock}
+		scaleoverCmdPlugin.origSources = snapshot(scaleoverCmdPlugin.sources)
+		scaleoverCmdPlugin.origTargets = snapshot(scaleoverCmdPlugin.targets)
+
+		run := scaleoverCmdPlugin.newRunState(0, opts)
+		Ω(scaleoverCmdPlugin.runScaleover(fakeCliConnection, run)).ShouldNot(Succeed())
+		Ω(scaleoverCmdPlugin.sources[0].countRequested).Should(Equal(3))
+		Ω(scaleoverCmdPlugin.targets[0].state).Should(Equal("stopped"))
+	})
+
+	It("reads outcomes for every failure or some of them", func() {
+		policy, err := ParseStartFailurePolicy("rollback", StartFailurePolicy{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(policy).Should(Equal(StartFailurePolicy{Timeout: StartRollback, Crash: StartRollback, Error: StartRollback}))
+
+		policy, err = ParseStartFailurePolicy("timeout=continue, error=abort", policy)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(policy).Should(Equal(StartFailurePolicy{Timeout: StartContinue, Crash: StartRollback, Error: StartAbort}))
+
+		_, err = ParseStartFailurePolicy("crash=ignore", policy)
+		Ω(err).Should(MatchError(`A start failure can abort, rollback or continue, not "ignore"`))
+		_, err = ParseStartFailurePolicy("oom=abort", policy)
+		Ω(err).Should(MatchError(`Start failures are timeout, crash or error, not "oom"`))
+	})
+})
@@ -0,0 +1,164 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//runState is everything needed to pick up an interrupted scaleover
+type runState struct {
+	Sources []savedApp `json:"sources"`
+	Targets []savedApp `json:"targets"`
+	Steps   []Step     `json:"steps"`
+	Step    int        `json:"step"`
+	Options Options    `json:"options"`
+}
+
+//savedApp is the state an app was in before the scaleover started
+type savedApp struct {
+	Name           string `json:"name"`
+	CountRunning   int    `json:"countRunning"`
+	CountRequested int    `json:"countRequested"`
+	StoppedCount   int    `json:"stoppedCount,omitempty"`
+	State          string `json:"state"`
+	Weight         int    `json:"weight,omitempty"`
+	// the routes it had, so a rollback after --resume only unmaps routes it didn't
+	Routes []savedRoute `json:"routes,omitempty"`
+}
+
+//savedRoute is a route as the state file records it
+type savedRoute struct {
+	Host   string `json:"host,omitempty"`
+	Domain string `json:"domain"`
+	Path   string `json:"path,omitempty"`
+	Port   int    `json:"port,omitempty"`
+}
+
+func newSavedApp(app AppStatus) savedApp {
+	return savedApp{
+		Name:           app.name,
+		CountRunning:   app.countRunning,
+		CountRequested: app.countRequested,
+		StoppedCount:   app.stoppedCount,
+		State:          app.state,
+		Weight:         app.weight,
+		Routes:         newSavedRoutes(app.routes),
+	}
+}
+
+func newSavedRoutes(routes []route) []savedRoute {
+	var saved []savedRoute
+	for _, r := range routes {
+		saved = append(saved, savedRoute{Host: r.host, Domain: r.domain, Path: r.path, Port: r.port})
+	}
+	return saved
+}
+
+func newSavedApps(apps []AppStatus) []savedApp {
+	saved := make([]savedApp, len(apps))
+	for i, app := range apps {
+		saved[i] = newSavedApp(app)
+	}
+	return saved
+}
+
+func (app savedApp) appStatus() AppStatus {
+	return AppStatus{
+		name:           app.Name,
+		countRunning:   app.CountRunning,
+		countRequested: app.CountRequested,
+		stoppedCount:   app.StoppedCount,
+		state:          app.State,
+		weight:         app.Weight,
+		routes:         app.routes(),
+	}
+}
+
+func (app savedApp) routes() []route {
+	var routes []route
+	for _, r := range app.Routes {
+		routes = append(routes, route{host: r.Host, domain: r.Domain, path: r.Path, port: r.Port})
+	}
+	return routes
+}
+
+func appStatuses(saved []savedApp) []AppStatus {
+	apps := make([]AppStatus, len(saved))
+	for i, app := range saved {
+		apps[i] = app.appStatus()
+	}
+	return apps
+}
+
+//DefaultStateFile lives next to the cf CLI's own config
+func DefaultStateFile() string {
+	home := os.Getenv("CF_HOME")
+	if home == "" {
+		home = os.Getenv("HOME")
+	}
+	if home == "" {
+		home = os.Getenv("USERPROFILE")
+	}
+	return filepath.Join(home, ".cf", "scaleover-state.json")
+}
+
+//save writes the state to a temp file first so a kill mid-write can't corrupt it
+func (run *runState) save(path string) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadRunState(path string) (*runState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No interrupted scaleover to resume, %s does not exist", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	run := &runState{}
+	if err = json.Unmarshal(data, run); err != nil {
+		return nil, fmt.Errorf("Unable to read scaleover state from %s: %s", path, err)
+	}
+	if len(run.Sources) == 0 || len(run.Targets) == 0 {
+		return nil, fmt.Errorf("Unable to read scaleover state from %s: it has no source or target apps", path)
+	}
+	if run.Step < 0 || run.Step > len(run.Steps) {
+		return nil, fmt.Errorf("Unable to read scaleover state from %s: step %d is outside its %d steps", path, run.Step, len(run.Steps))
+	}
+	return run, nil
+}
+
+func removeRunState(path string) {
+	if path != "" {
+		os.Remove(path)
+	}
+}
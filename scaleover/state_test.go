@@ -0,0 +1,132 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Run state", func() {
+	var scaleoverCmdPlugin *Scaleover
+	var fakeCliConnection *pluginfakes.FakeCliConnection
+	var dir string
+	var stateFile string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "scaleover")
+		Expect(err).NotTo(HaveOccurred())
+		stateFile = filepath.Join(dir, "nested", "state.json")
+
+		fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRequested: 10, countRunning: 10, state: "started"}},
+			targets: []*AppStatus{{name: "green", countRequested: 0, state: "stopped"}},
+		}
+		scaleoverCmdPlugin.origSources = snapshot(scaleoverCmdPlugin.sources)
+		scaleoverCmdPlugin.origTargets = snapshot(scaleoverCmdPlugin.targets)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should round trip through the state file", func() {
+		run := scaleoverCmdPlugin.newRunState(10*time.Minute, Options{BatchSize: 2, Leave: 1, StateFile: stateFile})
+		run.Step = 3
+		Expect(run.save(stateFile)).To(Succeed())
+
+		loaded, err := loadRunState(stateFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Step).To(Equal(3))
+		Expect(loaded.Steps).To(HaveLen(5))
+		Expect(loaded.Steps[0]).To(Equal(Step{App1: 8, App2: 2, Wait: time.Minute}))
+		Expect(loaded.Options.BatchSize).To(Equal(2))
+		Expect(loaded.Options.Leave).To(Equal(1))
+		Expect(appStatuses(loaded.Sources)).To(Equal(scaleoverCmdPlugin.origSources))
+		Expect(appStatuses(loaded.Targets)).To(Equal(scaleoverCmdPlugin.origTargets))
+	})
+
+	It("should remember the routes each app had", func() {
+		scaleoverCmdPlugin.origSources[0].routes = []route{{host: "www", domain: "example.com", path: "/api"}, {domain: "tcp.example.com", port: 1024}}
+		Expect(scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 1}).save(stateFile)).To(Succeed())
+
+		loaded, err := loadRunState(stateFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(appStatuses(loaded.Sources)).To(Equal(scaleoverCmdPlugin.origSources))
+		Expect(appStatuses(loaded.Targets)[0].routes).To(BeEmpty())
+	})
+
+	It("should explain when there is nothing to resume", func() {
+		_, err := loadRunState(filepath.Join(dir, "missing.json"))
+		Expect(err).To(MatchError("No interrupted scaleover to resume, " + filepath.Join(dir, "missing.json") + " does not exist"))
+	})
+
+	It("should refuse a state file with no apps", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "empty.json"), []byte(`{"sources":[],"targets":[]}`), 0600)).To(Succeed())
+
+		_, err := loadRunState(filepath.Join(dir, "empty.json"))
+		Expect(err).To(MatchError("Unable to read scaleover state from " + filepath.Join(dir, "empty.json") + ": it has no source or target apps"))
+	})
+
+	It("should refuse a state file whose step is past its last", func() {
+		run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 4})
+		run.Step = 4
+		Expect(run.save(stateFile)).To(Succeed())
+
+		_, err := loadRunState(stateFile)
+		Expect(err).To(MatchError("Unable to read scaleover state from " + stateFile + ": step 4 is outside its 3 steps"))
+	})
+
+	It("should record every step as it goes", func() {
+		run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 4, StateFile: stateFile})
+		Expect(scaleoverCmdPlugin.continueScaleover(fakeCliConnection, run)).To(Succeed())
+
+		saved, err := loadRunState(stateFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved.Step).To(Equal(3))
+		Expect(saved.Steps).To(HaveLen(3))
+	})
+
+	It("should continue a run from where it left off", func() {
+		run := scaleoverCmdPlugin.newRunState(0, Options{BatchSize: 1})
+		run.Step = 4
+		scaleoverCmdPlugin.sources[0].countRequested = 6
+		scaleoverCmdPlugin.targets[0].countRequested = 4
+		scaleoverCmdPlugin.targets[0].state = "started"
+
+		Expect(scaleoverCmdPlugin.continueScaleover(fakeCliConnection, run)).To(Succeed())
+		Expect(run.Step).To(Equal(10))
+		Expect(scaleoverCmdPlugin.targets[0].countRequested).To(Equal(10))
+		Expect(scaleoverCmdPlugin.sources[0].state).To(Equal("stopped"))
+		// 6 steps, each a scale up and a scale down, plus stopping app1 at the end
+		Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(13))
+	})
+
+	It("should default to a file next to the cf config", func() {
+		orig := os.Getenv("CF_HOME")
+		defer os.Setenv("CF_HOME", orig)
+
+		os.Setenv("CF_HOME", dir)
+		Expect(DefaultStateFile()).To(Equal(filepath.Join(dir, ".cf", "scaleover-state.json")))
+	})
+})
@@ -0,0 +1,217 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Step is the instance counts both apps should have after one step, and how long to wait before the next
+type Step struct {
+	App1 int           `json:"app1"`
+	App2 int           `json:"app2"`
+	Wait time.Duration `json:"wait"`
+	// stop the source instances before starting the target ones, to keep within --max-surge
+	DownFirst bool `json:"downFirst,omitempty"`
+}
+
+//Rollout is where a scaleover starts from and what it should end with
+type Rollout struct {
+	App1        int // requested instances of the source app
+	App2        int // requested instances of the target app
+	App2Running int
+	Target      int // instances the target app ends with
+	Leave       int
+	BatchSize   int
+	Duration    time.Duration
+}
+
+//resized is true when the target app ends with a different number of instances than the source has
+func (r Rollout) resized() bool {
+	return r.Target != r.App1
+}
+
+//app1At is the source count to go with app2 target instances. Each new instance replaces
+//an old one, unless the rollout is resized, when app1 comes down in proportion to app2's
+//progress towards Target, rounding so the sources keep the extra instance.
+func (r Rollout) app1At(app2 int) int {
+	if !r.resized() || r.Target <= r.App2 {
+		return max(r.App1-(app2-r.App2), r.Leave)
+	}
+	moved := (app2 - r.App2) * (r.App1 - r.Leave) / (r.Target - r.App2)
+	return max(r.App1-moved, r.Leave)
+}
+
+//Strategy decides the instance counts for each step of a scaleover
+type Strategy interface {
+	Plan(r Rollout) []Step
+}
+
+//Names accepted by --strategy
+const (
+	StrategyLinear      = "linear"
+	StrategyExponential = "exponential"
+	StrategyCanary      = "canary"
+	StrategySteps       = "steps"
+)
+
+//ValidStrategy is true for the strategies newStrategy knows
+func ValidStrategy(name string) bool {
+	switch name {
+	case StrategyLinear, StrategyExponential, StrategyCanary, StrategySteps:
+		return true
+	}
+	return false
+}
+
+//newStrategy picks the strategy named in the options
+func newStrategy(opts Options) Strategy {
+	switch opts.Strategy {
+	case StrategyExponential:
+		return exponentialStrategy{}
+	case StrategyCanary:
+		return canaryStrategy{}
+	case StrategySteps:
+		return percentStrategy{percents: opts.Steps}
+	}
+	return linearStrategy{}
+}
+
+//linearStrategy moves BatchSize instances every Duration/App1
+type linearStrategy struct{}
+
+func (linearStrategy) Plan(r Rollout) []Step {
+	batchSize := atLeastOne(r.BatchSize)
+	wait := r.Duration / time.Duration(atLeastOne(r.Target))
+	leave := r.Leave - r.App2Running
+	app1, app2 := r.App1, r.App2
+
+	var steps []Step
+	for count := r.Target - r.App2Running; count > 0; count -= batchSize {
+		app2 = min(app2+batchSize, r.Target)
+		if r.resized() {
+			app1 = r.app1At(app2)
+		} else {
+			app1 = max(app1-batchSize, leave)
+		}
+		steps = append(steps, Step{App1: max(app1, 0), App2: app2, Wait: wait})
+	}
+	return lastStepDoesNotWait(steps)
+}
+
+//exponentialStrategy doubles the number of instances moved each step
+type exponentialStrategy struct{}
+
+func (exponentialStrategy) Plan(r Rollout) []Step {
+	var targets []int
+	for app2, moving := r.App2, atLeastOne(r.BatchSize); app2 < r.Target; moving *= 2 {
+		app2 = min(app2+moving, r.Target)
+		targets = append(targets, app2)
+	}
+	return planTargets(r, targets, evenWaits(r.Duration, len(targets)))
+}
+
+//canaryStrategy moves one batch, waits the whole duration, then moves the rest
+type canaryStrategy struct{}
+
+func (canaryStrategy) Plan(r Rollout) []Step {
+	canary := min(r.App2+atLeastOne(r.BatchSize), r.Target)
+	targets := []int{canary}
+	if canary < r.Target {
+		targets = append(targets, r.Target)
+	}
+	return planTargets(r, targets, func(int) time.Duration { return r.Duration })
+}
+
+//percentStrategy brings the target app up to each percentage of its final count in turn
+type percentStrategy struct {
+	percents []int
+}
+
+func (s percentStrategy) Plan(r Rollout) []Step {
+	var targets []int
+	last := r.App2
+	for _, percent := range s.percents {
+		app2 := (r.Target*percent + 99) / 100
+		if app2 > last {
+			targets = append(targets, app2)
+			last = app2
+		}
+	}
+	return planTargets(r, targets, evenWaits(r.Duration, len(targets)))
+}
+
+//planTargets scales app1 down as app2 gains instances at each step
+func planTargets(r Rollout, app2Targets []int, wait func(int) time.Duration) []Step {
+	steps := make([]Step, len(app2Targets))
+	for i, app2 := range app2Targets {
+		steps[i] = Step{App1: max(r.app1At(app2), 0), App2: app2, Wait: wait(i)}
+	}
+	return lastStepDoesNotWait(steps)
+}
+
+func evenWaits(duration time.Duration, steps int) func(int) time.Duration {
+	return func(int) time.Duration {
+		return duration / time.Duration(atLeastOne(steps))
+	}
+}
+
+func lastStepDoesNotWait(steps []Step) []Step {
+	if len(steps) > 0 {
+		steps[len(steps)-1].Wait = 0
+	}
+	return steps
+}
+
+//ParseSteps reads a list like 10%,25%,50%,100%
+func ParseSteps(list string) ([]int, error) {
+	var percents []int
+	for _, field := range strings.Split(list, ",") {
+		percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(field), "%"))
+		if err != nil {
+			return nil, fmt.Errorf("Steps must be percentages like 10%%,50%%,100%%, not %q", field)
+		}
+		if percent < 1 || percent > 100 {
+			return nil, fmt.Errorf("Step %d%% must be between 1%% and 100%%", percent)
+		}
+		if len(percents) > 0 && percent <= percents[len(percents)-1] {
+			return nil, errors.New("Steps must increase")
+		}
+		percents = append(percents, percent)
+	}
+	return percents, nil
+}
+
+func atLeastOne(n int) int {
+	return max(n, 1)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
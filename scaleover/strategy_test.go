@@ -0,0 +1,159 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Strategies", func() {
+	var rollout Rollout
+
+	BeforeEach(func() {
+		rollout = Rollout{
+			App1:      10,
+			Target:    10,
+			BatchSize: 1,
+			Duration:  10 * time.Minute,
+		}
+	})
+
+	Describe("linear", func() {
+		It("moves one batch per step, evenly spaced", func() {
+			steps := linearStrategy{}.Plan(rollout)
+			Expect(steps).To(HaveLen(10))
+			Expect(steps[0]).To(Equal(Step{App1: 9, App2: 1, Wait: time.Minute}))
+			Expect(steps[9]).To(Equal(Step{App1: 0, App2: 10}))
+		})
+
+		It("caps the last batch", func() {
+			rollout.BatchSize = 3
+			steps := linearStrategy{}.Plan(rollout)
+			Expect(steps).To(Equal([]Step{
+				{App1: 7, App2: 3, Wait: time.Minute},
+				{App1: 4, App2: 6, Wait: time.Minute},
+				{App1: 1, App2: 9, Wait: time.Minute},
+				{App1: 0, App2: 10},
+			}))
+		})
+
+		It("leaves instances of the source app running", func() {
+			rollout.Leave = 2
+			steps := linearStrategy{}.Plan(rollout)
+			Expect(steps[len(steps)-1]).To(Equal(Step{App1: 2, App2: 10}))
+		})
+	})
+
+	Describe("exponential", func() {
+		It("doubles the instances moved each step", func() {
+			steps := exponentialStrategy{}.Plan(rollout)
+			Expect(steps).To(Equal([]Step{
+				{App1: 9, App2: 1, Wait: 150 * time.Second},
+				{App1: 7, App2: 3, Wait: 150 * time.Second},
+				{App1: 3, App2: 7, Wait: 150 * time.Second},
+				{App1: 0, App2: 10},
+			}))
+		})
+	})
+
+	Describe("canary", func() {
+		It("moves one batch then waits the whole duration before the rest", func() {
+			rollout.BatchSize = 2
+			steps := canaryStrategy{}.Plan(rollout)
+			Expect(steps).To(Equal([]Step{
+				{App1: 8, App2: 2, Wait: 10 * time.Minute},
+				{App1: 0, App2: 10},
+			}))
+		})
+	})
+
+	Describe("resized", func() {
+		It("brings the source down in proportion to the target's progress", func() {
+			rollout.Target = 4
+			steps := linearStrategy{}.Plan(rollout)
+			Expect(steps).To(Equal([]Step{
+				{App1: 8, App2: 1, Wait: 150 * time.Second},
+				{App1: 5, App2: 2, Wait: 150 * time.Second},
+				{App1: 3, App2: 3, Wait: 150 * time.Second},
+				{App1: 0, App2: 4},
+			}))
+		})
+
+		It("grows the target past the source's count", func() {
+			rollout.App1, rollout.Target = 4, 12
+			steps := exponentialStrategy{}.Plan(rollout)
+			Expect(steps).To(Equal([]Step{
+				{App1: 4, App2: 1, Wait: 150 * time.Second},
+				{App1: 3, App2: 3, Wait: 150 * time.Second},
+				{App1: 2, App2: 7, Wait: 150 * time.Second},
+				{App1: 0, App2: 12},
+			}))
+		})
+
+		It("still leaves instances of the source app running", func() {
+			rollout.Target, rollout.Leave = 4, 2
+			steps := linearStrategy{}.Plan(rollout)
+			Expect(steps[len(steps)-1]).To(Equal(Step{App1: 2, App2: 4}))
+		})
+	})
+
+	Describe("steps", func() {
+		It("brings the target up to each percentage in turn", func() {
+			steps := percentStrategy{percents: []int{10, 25, 50, 100}}.Plan(rollout)
+			Expect(steps).To(Equal([]Step{
+				{App1: 9, App2: 1, Wait: 150 * time.Second},
+				{App1: 7, App2: 3, Wait: 150 * time.Second},
+				{App1: 5, App2: 5, Wait: 150 * time.Second},
+				{App1: 0, App2: 10},
+			}))
+		})
+
+		It("skips percentages that don't add an instance", func() {
+			rollout.App1, rollout.Target = 2, 2
+			steps := percentStrategy{percents: []int{10, 25, 50, 100}}.Plan(rollout)
+			Expect(steps).To(Equal([]Step{
+				{App1: 1, App2: 1, Wait: 5 * time.Minute},
+				{App1: 0, App2: 2},
+			}))
+		})
+
+		It("parses a list of percentages", func() {
+			Expect(ParseSteps("10%,25%, 50%,100")).To(Equal([]int{10, 25, 50, 100}))
+		})
+
+		It("rejects a list that isn't increasing percentages", func() {
+			_, err := ParseSteps("50%,25%")
+			Expect(err).To(MatchError("Steps must increase"))
+			_, err = ParseSteps("10%,150%")
+			Expect(err).To(MatchError("Step 150% must be between 1% and 100%"))
+			_, err = ParseSteps("ten")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("choosing a strategy", func() {
+		It("defaults to linear", func() {
+			Expect(newStrategy(DefaultOptions())).To(Equal(linearStrategy{}))
+		})
+
+		It("takes the strategy from the options", func() {
+			Expect(newStrategy(Options{Strategy: StrategyExponential})).To(Equal(exponentialStrategy{}))
+			Expect(newStrategy(Options{Strategy: StrategySteps, Steps: []int{10, 100}})).To(Equal(percentStrategy{percents: []int{10, 100}}))
+		})
+	})
+})
@@ -0,0 +1,47 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import "fmt"
+
+//CapacityMatchMemory sizes the targets to hold as much memory as the sources have now
+const CapacityMatchMemory = "memory"
+
+//ValidCapacityMatch is true for the values --capacity-match accepts
+func ValidCapacityMatch(match string) bool {
+	return match == "" || match == CapacityMatchMemory
+}
+
+//resolveTarget works out how many instances the targets end with, so later steps and
+//resumed runs don't have to. It's the sources' instances unless the options say otherwise.
+func (cmd *Scaleover) resolveTarget(opts Options) (Options, error) {
+	if opts.TargetInstances > 0 || opts.CapacityMatch != CapacityMatchMemory {
+		return opts, nil
+	}
+
+	need := memoryFor(cmd.sources, counts(cmd.sources))
+	for _, app := range cmd.apps() {
+		if app.memory <= 0 {
+			return opts, fmt.Errorf("Can't match memory, %s doesn't say how much memory its instances have", app.name)
+		}
+	}
+	target := 1
+	for memoryFor(cmd.targets, cmd.targetCounts(target)) < need {
+		target++
+	}
+	cmd.say("Matching the %dM of %s takes %d instances of %s\n", need, names(cmd.sources), target, names(cmd.targets))
+	opts.TargetInstances = target
+	return opts, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 Joshua Kruck
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleover
+
+import (
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Target instances", func() {
+	var scaleoverCmdPlugin *Scaleover
+
+	BeforeEach(func() {
+		scaleoverCmdPlugin = &Scaleover{
+			sources: []*AppStatus{{name: "blue", countRequested: 10, state: "started", memory: 256}},
+			targets: []*AppStatus{{name: "green", state: "stopped", memory: 1024, weight: 1}},
+		}
+	})
+
+	It("matches the sources' memory with as few target instances as hold it", func() {
+		opts, err := scaleoverCmdPlugin.resolveTarget(Options{CapacityMatch: CapacityMatchMemory})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(opts.TargetInstances).Should(Equal(3))
+	})
+
+	It("splits the memory between weighted targets", func() {
+		scaleoverCmdPlugin.targets = append(scaleoverCmdPlugin.targets, &AppStatus{name: "canary", state: "stopped", memory: 128, weight: 1})
+		opts, _ := scaleoverCmdPlugin.resolveTarget(Options{CapacityMatch: CapacityMatchMemory})
+		Ω(opts.TargetInstances).Should(Equal(5))
+	})
+
+	It("keeps a count it's given", func() {
+		opts, _ := scaleoverCmdPlugin.resolveTarget(Options{TargetInstances: 12, CapacityMatch: CapacityMatchMemory})
+		Ω(opts.TargetInstances).Should(Equal(12))
+
+		opts, _ = scaleoverCmdPlugin.resolveTarget(Options{})
+		Ω(opts.TargetInstances).Should(Equal(0))
+	})
+
+	It("can't match memory it doesn't know", func() {
+		scaleoverCmdPlugin.targets[0].memory = 0
+		_, err := scaleoverCmdPlugin.resolveTarget(Options{CapacityMatch: CapacityMatchMemory})
+		Ω(err).Should(MatchError("Can't match memory, green doesn't say how much memory its instances have"))
+	})
+
+	It("scales the apps to different counts", func() {
+		fakeCliConnection := &pluginfakes.FakeCliConnection{}
+		Ω(scaleoverCmdPlugin.doScaleover(fakeCliConnection, 0, Options{BatchSize: 1, TargetInstances: 4})).Should(Succeed())
+		Ω(scaleoverCmdPlugin.targets[0].countRequested).Should(Equal(4))
+		Ω(scaleoverCmdPlugin.sources[0].state).Should(Equal("stopped"))
+	})
+})
@@ -15,33 +15,29 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/andrew-d/go-termutil"
 	"github.com/cloudfoundry/cli/plugin"
+	"github.com/krujos/scaleover-plugin/scaleover"
 )
 
-//AppStatus represents the sattus of a app in CF
-type AppStatus struct {
-	name           string
-	countRunning   int
-	countRequested int
-	state          string
-	routes         []string
-}
-
-//ScaleoverCmd is this plugin
+//ScaleoverCmd is this plugin, a front end to the scaleover package
 type ScaleoverCmd struct {
-	app1     *AppStatus
-	app2     *AppStatus
-	maxcount int
+	clock scaleover.Clock // nil for the system clock
 }
 
+//exit ends the plugin, the end to end specs swap it out to see how a scaleover ended
+var exit = os.Exit
+
 //GetMetadata returns metatada
 func (cmd *ScaleoverCmd) GetMetadata() plugin.PluginMetadata {
 	return plugin.PluginMetadata{
@@ -56,13 +52,44 @@ func (cmd *ScaleoverCmd) GetMetadata() plugin.PluginMetadata {
 				Name:     "scaleover",
 				HelpText: "Roll traffic from one application to another",
 				UsageDetails: plugin.Usage{
-					Usage: "cf scaleover APP1 APP2 ROLLOVER_DURATION",
-          Options: map[string]string{
-						"-no-route-check":   "Since both apps are live at the same time, there is assumed use of a shared route (default true)",
-						"-wait-for-start":   "Should scaleover wait for confirmation that the scaled up instace(s) are 'started' before scaling down? (default false)",
-            "-post-start-sleep": "How long should scaleover wait after the new instances are considered 'started' for the app itself to initialize/bootstrap. Supports standard duration strings (eg '10s', '1m', etc). Used ONLY in conjunction with `--wait-for-start`. (default 0)",
-            "-leave":            "How many 'blue' instances should remain running when using scaleover to 'green'? (default 1/stopped)",
-            "-batch-size":       "How many instances should be scaled (both up/down) at a time? (default 1)",
+					Usage: "cf scaleover APP1[,APP...] APP2[:WEIGHT][,APP[:WEIGHT]...] ROLLOVER_DURATION [OPTIONS]",
+					Options: map[string]string{
+						"-from":                "Source apps to drain, in proportion to their current instance counts, e.g. `--from blue,red`. Takes the place of APP1.",
+						"-to":                  "Target apps to fill, with optional weights for how the instances are split between them, e.g. `--to green:3,canary:1`. Takes the place of APP2. (default weight 1)",
+						"-config":              "Read the apps, duration and options from a YAML or JSON rollout file, overridden by any given on the command line. See the README for the format.",
+						"-no-route-check":      "Since both apps are live at the same time, there is assumed use of a shared route (default true)",
+						"-no-quota-check":      "Don't check that the extra instances fit in the org and space memory quotas before starting. (default check)",
+						"-wait-for-start":      "Should scaleover wait for confirmation that the scaled up instace(s) are 'started' before scaling down? (default false)",
+						"-post-start-sleep":    "How long should scaleover wait after the new instances are considered 'started' for the app itself to initialize/bootstrap. Supports standard duration strings (eg '10s', '1m', etc). Used ONLY in conjunction with `--wait-for-start`. (default 0)",
+						"-start-timeout":       "How long `--wait-for-start` waits for every new instance to be running before it counts as a failure. Implies `--wait-for-start`. (default 5m)",
+						"-on-start-failure":    "What to do when the new instances time out, crash (crashed, flapping or down) or can't be checked: `abort`, `rollback` or `continue`, for all of them or each, e.g. `timeout=continue,crash=rollback`. Implies `--wait-for-start`. (default abort, or rollback with `--rollback-on-failure`)",
+						"-target-instances":    "How many instances APP2 should end with, when it isn't the number APP1 has. APP1 comes down in proportion as APP2 comes up. (default APP1's instances)",
+						"-capacity-match":      "`memory` works out APP2's instances from both apps' memory per instance, so APP2 ends with at least as much memory as APP1 has. (default none)",
+						"-balance":             "`plan` scales APP1 down by the plan's counts, `ready` only by as many APP2 instances as are running, so serving capacity never dips when some are slow or fail to start. `ready` implies `--wait-for-start` and carries on past start failures. (default plan)",
+						"-min-available":       "Never let the running instances of both apps together drop below N, or P% of APP1's instances. Steps stop old instances first or split up to keep to it, and the scaleover stops if it's broken. Implies `--wait-for-start`. (default none)",
+						"-max-surge":           "Never request more than N, or P% of APP1's instances, on top of what both apps had at the start. Steps stop old instances before starting new ones, or split up, to keep to it. (default none)",
+						"-leave":               "How many 'blue' instances should remain running when using scaleover to 'green'? A count, or a percentage of 'blue' like 10%, rounded up. (default 1/stopped)",
+						"-batch-size":          "How many instances should be scaled (both up/down) at a time? A count, or a percentage of 'blue' like 10%, rounded up. (default 1)",
+						"-state-file":          "Where to record progress so an interrupted scaleover can be continued with `cf scaleover --resume`. (default $CF_HOME/.cf/scaleover-state.json)",
+						"-resume":              "Continue the interrupted scaleover recorded in the state file, e.g. `cf scaleover --resume [--state-file PATH]`",
+						"-on-interrupt":        "What to do when interrupted with no terminal to ask on, either `hold` (stop where it is, resumable with `--resume`) or `rollback`. (default hold)",
+						"-health-url":          "Before scaling APP1 down, poll this URL until the new instances answer correctly, e.g. APP2's own route. (default none)",
+						"-health-status":       "HTTP status code `--health-url` must answer with. (default 200)",
+						"-health-body":         "Text the `--health-url` response body must contain. (default none)",
+						"-health-body-regex":   "Regular expression the `--health-url` response body must match. (default none)",
+						"-health-successes":    "How many correct answers in a row `--health-url` must give. (default 1)",
+						"-health-timeout":      "How long to keep polling `--health-url` before giving up. (default 2m)",
+						"-strategy":            "How instances are moved: `linear` (--batch-size at a time, evenly spaced), `exponential` (doubling each step), or `canary` (one --batch-size, wait the whole duration, then the rest). (default linear)",
+						"-steps":               "Bring APP2 up to each percentage of its final count in turn, e.g. `10%,25%,50%,100%`, spread evenly over the duration. Implies `--strategy steps`.",
+						"-dry-run":             "Print every step the scaleover would take, with the cf commands and timings, without changing either app",
+						"-require-route":       "Refuse to start unless this HOST.DOMAIN[/PATH] is mapped to both apps, rather than any shared route. Repeat for more routes. (default none)",
+						"-map-route":           "Map this HOST.DOMAIN[/PATH] to APP2 before scaling it up, if it isn't mapped already. (default none)",
+						"-unmap-old":           "Unmap the `--map-route` route from APP1 once it's down to its `--leave` count. (default false)",
+						"-output":              "`text` for the usual progress display, or `json` for one JSON object per event on stdout. (default text)",
+						"-retries":             "How many times to retry a failed `cf scale`, `start` or `stop` before giving up. (default 0)",
+						"-retry-backoff":       "How long to wait before the first retry, doubling for each one after. (default 5s)",
+						"-log-file":            "Also append one JSON object per event to this file. (default none)",
+						"-rollback-on-failure": "Restore both apps to their original instance counts and states if the scaled up instances crash or fail to start. Implies `--wait-for-start`. (default false)",
 					},
 				},
 			},
@@ -74,74 +101,6 @@ func main() {
 	plugin.Start(new(ScaleoverCmd))
 }
 
-func (cmd *ScaleoverCmd) usage(args []string) error {
-  badArgs := 4 != len(args)
-
-	for i := 4; i < len(args); i++ {
-		switch args[i] {
-			case "--no-route-check":
-				badArgs = false
-			case "--leave":
-				if i < len(args) -1 {
-					_, err := strconv.Atoi(args[i + 1])
-					badArgs = err != nil
-				}
-      case "--batch-size":
-				if i < len(args) -1 {
-					_, err := strconv.Atoi(args[i + 1])
-          badArgs = err != nil
-				}
-      case "--post-start-sleep":
-				if i < len(args) -1 {
-          badArgs = args[i + 1] == ""
-				}
-		}
-	}
-
-	if badArgs {
-		return errors.New("Usage: cf scaleover\n\tcf scaleover APP1 APP2 ROLLOVER_DURATION [--no-route-check] [--leave N]")
-	}
-
-	return nil
-}
-
-func (cmd *ScaleoverCmd) parseArgs(args []string) (bool, int, bool, time.Duration, int) {
-	enforceRoutes := true
-  waitForStarted := false
-  batchSize :=1
-	leave := 0
-  var postStartSleep time.Duration
-  var err error
-
-	for i := 4; i < len(args); i++ {
-		switch(args[i]) {
-			case "--no-route-check":
-				enforceRoutes = false
-      case "--wait-for-start":
-				waitForStarted = true
-      case "--leave":
-				if i < len(args) -1 {
-          leave, err = strconv.Atoi(args[i + 1])
-				}
-      case "--batch-size":
-				if i < len(args) -1 {
-          batchSize, err = strconv.Atoi(args[i + 1])
-				}
-      case "--post-start-sleep":
-				if i < len(args) -1 {
-          postStartSleep, err = cmd.parseTime(args[i + 1])
-				}
-		}
-	}
-
-  if err == nil {
-		return enforceRoutes, leave, waitForStarted, postStartSleep, batchSize
-	}
-
-  //defaults on error
-  return enforceRoutes, 0, waitForStarted, postStartSleep, 1
-}
-
 func (cmd *ScaleoverCmd) parseTime(duration string) (time.Duration, error) {
 	rolloverTime := time.Duration(0)
 	var err error
@@ -166,188 +125,66 @@ func (cmd *ScaleoverCmd) Run(cliConnection plugin.CliConnection, args []string)
 
 //ScaleoverCommand creates a new instance of this plugin
 func (cmd *ScaleoverCmd) ScaleoverCommand(cliConnection plugin.CliConnection, args []string) {
-	if err := cmd.usage(args); nil != err {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-
-	rolloverTime, err := cmd.parseTime(args[3])
+	inv, err := cmd.parseArgs(args)
 	if nil != err {
 		fmt.Println(err)
-		os.Exit(1)
-	}
-
-	enforceRoutes, leave, waitForStarted, postStartSleep, batchSize := cmd.parseArgs(args)
-
-	// The getAppStatus calls will exit with an error if the named apps don't exist
-	if cmd.app1, err = cmd.getAppStatus(cliConnection, args[1]); nil != err {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-
-	if cmd.app2, err = cmd.getAppStatus(cliConnection, args[2]); nil != err {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-
-	if enforceRoutes {
-		if err = cmd.errorIfNoSharedRoute(); err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
+		exit(1)
 	}
 
-	cmd.showStatus()
-
-	count := cmd.app1.countRequested
-	if count == 0 {
-		fmt.Println("\nThere are no instances of the source app to scale over\n")
-		os.Exit(0)
-	}
-	sleepInterval := time.Duration(rolloverTime.Nanoseconds() / int64(count))
-
-	cmd.doScaleover(cliConnection, count, leave, sleepInterval, waitForStarted, postStartSleep, batchSize)
-	fmt.Println()
-}
-
-func (cmd *ScaleoverCmd) doScaleover(cliConnection plugin.CliConnection,
-	count int, leave int, sleepInterval time.Duration, waitForStarted bool, postStartSleep time.Duration, batchSize int) {
-  origInstances := count
-	count -= cmd.app2.countRunning
-	leave -= cmd.app2.countRunning
-
-  for count > 0 {
-    cmd.app2.scaleUp(cliConnection, origInstances, batchSize)
-    cmd.app1.scaleDown(cliConnection, leave, batchSize, cmd.app2, waitForStarted, postStartSleep)
+	// SIGINT and SIGTERM are handled between steps, and handed back once the scaleover is over
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
 
-		count-=batchSize
-		cmd.showStatus()
-		if count > 0 {
-			time.Sleep(sleepInterval)
+	engine := &scaleover.Scaleover{Clock: cmd.clock, Out: os.Stdout, Interrupts: interrupts}
+	if termutil.Isatty(os.Stdin.Fd()) {
+		engine.OnInterrupt = func(string) string {
+			return promptInterruptAction(bufio.NewReader(os.Stdin), os.Stdout)
 		}
 	}
-}
-
-func (cmd *ScaleoverCmd) getAppStatus(cliConnection plugin.CliConnection, name string) (*AppStatus, error) {
-	app, err := cliConnection.GetApp(name)
-	if nil != err {
-		return nil, err
-	}
-
-	status := &AppStatus{
-		name:           name,
-		countRunning:   0,
-		countRequested: 0,
-		state:          "unknown",
-		routes:         make([]string, len(app.Routes)),
-	}
-
-	status.state = app.State
-	if app.State != "stopped" {
-		status.countRequested = app.InstanceCount
-	}
-	status.countRunning = app.RunningInstances
-	for idx, route := range app.Routes {
-		status.routes[idx] = route.Host + "." + route.Domain.Name
-	}
-	return status, nil
-}
-
-func (app *AppStatus) scaleUp(cliConnection plugin.CliConnection, origInstances int, batchSize int) {
-	app.countRequested+=batchSize
-  if(app.countRequested > origInstances){
-    app.countRequested = origInstances;
-  }
-  cliConnection.CliCommandWithoutTerminalOutput("scale", "-i", strconv.Itoa(app.countRequested), app.name)
-
-  // If not already started, start it
-	if app.state == "stopped" {
-		cliConnection.CliCommandWithoutTerminalOutput("start", app.name)
-		app.state = "started"
-	}
-}
-
-func (app *AppStatus) scaleDown(cliConnection plugin.CliConnection, leave int,
-  batchSize int, app2 *AppStatus, waitForStarted bool, postStartSleep time.Duration) {
-
-  if waitForStarted {
-    for {
-      refreshedApp2Status, err := cliConnection.GetApp(app2.name)
-      if nil != err {
-    		fmt.Println("Can't scale down. Error")
-        break
-    	}
-
-      // iterate through instances
-      hasStarting := false
-      for _,instance := range refreshedApp2Status.Instances {
-        if(instance.State != "running") {
-          hasStarting = true
-          time.Sleep(1 * time.Second)
-          break //instance loop
-        }
-      }
-
-      if !hasStarting {
-        time.Sleep(postStartSleep)
-        break
-      } // while loop
-    }
-  }
-
-  app.countRequested-=batchSize
-
-  if(app.countRequested < leave) {
-    app.countRequested = leave
-  }
-
-	// If going to zero, stop the app, and force to one instance
-	if app.countRequested <= 0 {
-    app.countRequested = 1
-		cliConnection.CliCommandWithoutTerminalOutput("stop", app.name)
-		app.state = "stopped"
-	}
-
-	cliConnection.CliCommandWithoutTerminalOutput("scale", "-i", strconv.Itoa(app.countRequested), app.name)
-
-}
-
-func (cmd *ScaleoverCmd) showStatus() {
 	if termutil.Isatty(os.Stdout.Fd()) {
-		fmt.Printf("%s (%s) %s %s %s (%s) \r",
-			cmd.app1.name,
-			cmd.app1.state,
-			strings.Repeat("<", cmd.app1.countRequested),
-			strings.Repeat(">", cmd.app2.countRequested),
-			cmd.app2.name,
-			cmd.app2.state,
-		)
+		engine.OnStatus = showProgress
+	}
+	if inv.resume {
+		err = engine.Resume(cliConnection, inv.opts.StateFile)
 	} else {
-		fmt.Printf("%s (%s) %d instances, %s (%s) %d instances\n",
-			cmd.app1.name,
-			cmd.app1.state,
-			cmd.app1.countRequested,
-			cmd.app2.name,
-			cmd.app2.state,
-			cmd.app2.countRequested,
-		)
+		err = engine.Run(cliConnection, inv.sources, inv.targets, inv.weights, inv.duration, inv.opts)
 	}
-}
-
-func (cmd *ScaleoverCmd) appsShareARoute() bool {
-	for _, r1 := range cmd.app1.routes {
-		for _, r2 := range cmd.app2.routes {
-			if r1 == r2 {
-				return true
-			}
+	if err != nil {
+		exit(1)
+	}
+}
+
+//showProgress redraws the apps' requested instances in place
+func showProgress(app1 *scaleover.AppEvent, app2 *scaleover.AppEvent) {
+	fmt.Printf("%s (%s) %s %s %s (%s) \r",
+		app1.Name,
+		app1.State,
+		strings.Repeat("<", app1.Requested),
+		strings.Repeat(">", app2.Requested),
+		app2.Name,
+		app2.State,
+	)
+}
+
+//promptInterruptAction asks what to do until it gets an answer it understands
+func promptInterruptAction(in *bufio.Reader, out io.Writer) string {
+	for {
+		fmt.Fprint(out, "\nScaleover interrupted. [c]ontinue, [h]old here, [a]bort as-is or [r]oll back to original counts? ")
+		answer, err := in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "c", scaleover.InterruptContinue:
+			return scaleover.InterruptContinue
+		case "h", scaleover.InterruptHold:
+			return scaleover.InterruptHold
+		case "a", scaleover.InterruptAbort:
+			return scaleover.InterruptAbort
+		case "r", scaleover.InterruptRollback, "roll back":
+			return scaleover.InterruptRollback
+		}
+		if err != nil {
+			// nobody is there to answer, leave things resumable
+			return scaleover.InterruptHold
 		}
 	}
-	return false
-}
-
-func (cmd *ScaleoverCmd) errorIfNoSharedRoute() error {
-	if cmd.appsShareARoute() {
-		return nil
-	}
-	return errors.New("Apps do not share a route!")
 }
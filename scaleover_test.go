@@ -15,372 +15,387 @@
 package main
 
 import (
-	"errors"
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
-	"code.cloudfoundry.org/cli/plugin/models"
-	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	"github.com/krujos/scaleover-plugin/scaleover"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("Scaleover", func() {
 	var scaleoverCmdPlugin *ScaleoverCmd
-	var fakeCliConnection *pluginfakes.FakeCliConnection
-	var status *AppStatus
-	domain := plugin_models.GetApp_DomainFields{Name: "cfapps.io"}
 
-	Describe("getAppStatus", func() {
+	BeforeEach(func() {
+		scaleoverCmdPlugin = &ScaleoverCmd{}
+	})
 
-		BeforeEach(func() {
-			fakeCliConnection = &pluginfakes.FakeCliConnection{}
-			scaleoverCmdPlugin = &ScaleoverCmd{}
-		})
+	Describe("It should handle weird time inputs", func() {
+		It("like a negative number", func() {
+			var err error
+			_, err = scaleoverCmdPlugin.parseTime("-1m")
 
-		It("should Fail Without App 1", func() {
-			app := plugin_models.GetAppModel{}
-			fakeCliConnection.GetAppReturns(app, errors.New("App app1 not found"))
-			//the command above shuld return nil through a cli fake?
-			_, err := scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
-			Expect(err.Error()).To(Equal("App app1 not found"))
+			Expect(err.Error()).To(Equal("Duration must be a positive number in the format of 1m"))
 		})
 
-		It("should not start a stopped target with 1 instance", func() {
-			app := plugin_models.GetAppModel{State: "stopped"}
-			fakeCliConnection.GetAppReturns(app, nil)
+		It("like zero", func() {
+			var t time.Duration
+			t, _ = scaleoverCmdPlugin.parseTime("0m")
+			one, _ := time.ParseDuration("0s")
+			Expect(t).To(Equal(one))
+		})
+	})
 
-			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+	Describe("Usage", func() {
+		It("shows usage for too few arguments", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover"}))).NotTo(BeNil())
+		})
 
-			Expect(status.name).To(Equal("app1"))
-			Expect(status.countRequested).To(Equal(0))
-			Expect(status.countRunning).To(Equal(0))
-			Expect(status.state).To(Equal("stopped"))
+		It("shows usage for too many arguments", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "foo"}))).NotTo(BeNil())
 		})
 
-		It("should report the correct number of instances", func() {
-			app := plugin_models.GetAppModel{
-				InstanceCount:    10,
-				RunningInstances: 10,
-				State:            "started",
-			}
-			fakeCliConnection.GetAppReturns(app, nil)
+		It("is just right", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m"}))).To(BeNil())
+		})
 
-			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+		It("is okay with --no-route-check at the end", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--no-route-check"}))).To(BeNil())
+		})
 
-			Expect(status.name).To(Equal("app1"))
-			Expect(status.countRequested).To(Equal(10))
-			Expect(status.countRunning).To(Equal(10))
+		It("is okay with --leave n at the end", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--leave", "1"}))).To(BeNil())
 		})
 
-		It("should keep a stoped app stopped with 10 instances", func() {
+		It("is okay with --no-route-check and --leave n at the end", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--no-route-check", "--leave", "1"}))).To(BeNil())
+		})
 
-			app := plugin_models.GetAppModel{
-				InstanceCount:    10,
-				RunningInstances: 0,
-				State:            "stopped",
-			}
-			fakeCliConnection.GetAppReturns(app, nil)
+		It("is N should fail if not a number", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--leave", "a"}))).NotTo(BeNil())
+		})
 
-			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
+		It("takes --no-route-check in an unusual position", func() {
+			args := []string{"scaleover", "two", "--no-route-check", "three", "1m"}
+			Expect(parseError(scaleoverCmdPlugin.parseArgs(args))).To(BeNil())
+			Expect(parsedOptions(scaleoverCmdPlugin.parseArgs(args)).EnforceRoutes).To(BeFalse())
+		})
 
-			Expect(status.name).To(Equal("app1"))
-			Expect(status.countRequested).To(Equal(0))
-			Expect(status.countRunning).To(Equal(0))
-			Expect(status.state).To(Equal("stopped"))
+		It("takes options before the apps", func() {
+			inv, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--leave", "1", "--wait-for-start", "two", "three", "1m"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inv.sources).To(Equal([]string{"two"}))
+			Expect(inv.targets).To(Equal([]string{"three"}))
+			Expect(inv.opts.Leave).To(Equal(1))
+			Expect(inv.opts.WaitForStarted).To(BeTrue())
 		})
 
-		It("should report zero requested instances for a stopped app", func() {
-			app := plugin_models.GetAppModel{
-				InstanceCount:    10,
-				RunningInstances: 10,
-				State:            "stopped",
-			}
+		It("takes --flag=value", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--leave=2", "--batch-size=3"}))
+			Expect(opts.Leave).To(Equal(2))
+			Expect(opts.BatchSize).To(Equal(3))
+		})
 
-			fakeCliConnection.GetAppReturns(app, nil)
+		It("names an unknown option", func() {
+			err := parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--leeve", "1"}))
+			Expect(err.Error()).To(HavePrefix("Unknown option --leeve\n"))
+			Expect(err.Error()).To(ContainSubstring("Usage: cf scaleover"))
+		})
 
-			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
-			Ω(status.countRequested).To(Equal(0))
+		It("names an option that's missing its value", func() {
+			err := parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--leave"}))
+			Expect(err.Error()).To(HavePrefix("--leave needs a value\n"))
 		})
 
-		It("should populate the routes for an app with one url", func() {
-			routes := []plugin_models.GetApp_RouteSummary{
-				{
-					Host:   "app",
-					Domain: domain,
-				},
-			}
+		It("names an option with a malformed value", func() {
+			err := parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--batch-size=lots"}))
+			Expect(err.Error()).To(HavePrefix(`Invalid value "lots" for --batch-size`))
+		})
 
-			app := plugin_models.GetAppModel{Routes: routes}
-			fakeCliConnection.GetAppReturns(app, nil)
-			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
-			Expect(len(status.routes)).To(Equal(1))
-			Expect(status.routes[0]).To(Equal("app.cfapps.io"))
-		})
-
-		It("should populate the routes for an app with three urls", func() {
-
-			routes := []plugin_models.GetApp_RouteSummary{
-				{
-					Host:   "app",
-					Domain: domain,
-				},
-				{
-					Host:   "foo-app",
-					Domain: domain,
-				},
-				{
-					Host:   "foo-app-b",
-					Domain: domain,
-				},
-			}
+		It("takes --batch-size and --leave as percentages of the source", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--batch-size", "25%", "--leave=10%"}))
+			Expect(opts.BatchSizePercent).To(Equal(25))
+			Expect(opts.LeavePercent).To(Equal(10))
 
-			app := plugin_models.GetAppModel{Routes: routes}
-			fakeCliConnection.GetAppReturns(app, nil)
+			opts = parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--batch-size", "25%", "--batch-size", "2"}))
+			Expect(opts.BatchSizePercent).To(Equal(0))
+			Expect(opts.BatchSize).To(Equal(2))
 
-			status, _ = scaleoverCmdPlugin.getAppStatus(fakeCliConnection, "app1")
-			Expect(len(status.routes)).To(Equal(3))
-			Expect(status.routes[0]).To(Equal("app.cfapps.io"))
-			Expect(status.routes[1]).To(Equal("foo-app.cfapps.io"))
-			Expect(status.routes[2]).To(Equal("foo-app-b.cfapps.io"))
+			err := parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--leave", "0%"}))
+			Expect(err.Error()).To(HavePrefix(`Invalid value "0%" for --leave`))
+			err = parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--batch-size", "150%"}))
+			Expect(err.Error()).To(HavePrefix(`Invalid value "150%" for --batch-size`))
 		})
 
-	})
+		It("takes a target instance count or a capacity match, but not both", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--target-instances", "12"}))
+			Expect(opts.TargetInstances).To(Equal(12))
+			opts = parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--capacity-match", "memory"}))
+			Expect(opts.CapacityMatch).To(Equal(scaleover.CapacityMatchMemory))
 
-	Describe("It should handle weird time inputs", func() {
-		BeforeEach(func() {
-			scaleoverCmdPlugin = &ScaleoverCmd{}
+			err := parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--target-instances", "0"}))
+			Expect(err.Error()).To(HavePrefix("--target-instances must be at least 1"))
+			err = parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--capacity-match", "disk"}))
+			Expect(err.Error()).To(HavePrefix("--capacity-match must be memory, not disk"))
+			err = parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--target-instances", "2", "--capacity-match", "memory"}))
+			Expect(err.Error()).To(HavePrefix("--target-instances and --capacity-match don't go together"))
 		})
 
-		It("like a negative number", func() {
-			var err error
-			_, err = scaleoverCmdPlugin.parseTime("-1m")
+		It("wants a batch size of at least 1", func() {
+			err := parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--batch-size", "0"}))
+			Expect(err.Error()).To(HavePrefix("--batch-size must be at least 1"))
+		})
 
-			Expect(err.Error()).To(Equal("Duration must be a positive number in the format of 1m"))
+		It("wants --wait-for-start before --post-start-sleep means anything", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--post-start-sleep", "5s"}))).NotTo(BeNil())
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--wait-for-start", "--post-start-sleep", "5s"}))
+			Expect(opts.WaitForStarted).To(BeTrue())
+			Expect(opts.PostStartSleep).To(Equal(5 * time.Second))
 		})
 
-		It("like zero", func() {
-			var t time.Duration
-			t, _ = scaleoverCmdPlugin.parseTime("0m")
-			one, _ := time.ParseDuration("0s")
-			Expect(t).To(Equal(one))
+		It("takes --resume without apps", func() {
+			inv, err := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--resume", "--state-file", "/tmp/scaleover.json"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inv.resume).To(BeTrue())
+			Expect(inv.opts.StateFile).To(Equal("/tmp/scaleover.json"))
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--resume", "two", "three", "1m"}))).NotTo(BeNil())
 		})
-	})
 
-	Describe("scale up", func() {
-		var appStatus *AppStatus
+		It("won't take options --resume would ignore", func() {
+			err := parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "--resume", "--output", "json", "--retries", "3"}))
+			Expect(err.Error()).To(HavePrefix("--resume carries on with the options it was started with, --output can't be changed\n"))
+		})
 
-		BeforeEach(func() {
-			appStatus = &AppStatus{
-				name:           "foo",
-				countRequested: 1,
-				countRunning:   1,
-				state:          "stopped",
-			}
-			fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		It("names every strategy when the one given isn't known", func() {
+			err := parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--strategy", "random"}))
+			Expect(err.Error()).To(HavePrefix("--strategy must be linear, exponential, canary or steps, not random"))
+		})
+	})
 
+	Describe("Routes", func() {
+		It("Should ignore route sanity if --no-route-check is at the end of args", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--no-route-check"}))
+			Expect(opts.EnforceRoutes).To(BeFalse())
 		})
 
-		It("Starts a stopped app", func() {
-			appStatus.scaleUp(fakeCliConnection, 1, 1)
-			Expect(appStatus.state).To(Equal("started"))
+		It("Should carfuly consider routes if --no-route-check is not in the args", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m"}))
+			Expect(opts.EnforceRoutes).To(BeTrue())
 		})
 
-		It("It increments the amount requested", func() {
-			running := appStatus.countRunning
-			appStatus.scaleUp(fakeCliConnection, 3, 1)
-			Expect(appStatus.countRequested).To(Equal(running + 1))
+		It("can be given more than once", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--require-route", "node-prod.cfapps.io", "--require-route", "api.cfapps.io/v2"}
+			Ω(parseError(scaleoverCmdPlugin.parseArgs(args))).Should(BeNil())
+			Ω(parsedOptions(scaleoverCmdPlugin.parseArgs(args)).RequireRoutes).Should(Equal([]string{"node-prod.cfapps.io", "api.cfapps.io/v2"}))
 		})
 
-		It("Leaves a started app started", func() {
-			appStatus.state = "started"
-			appStatus.scaleUp(fakeCliConnection, 1, 1)
-			Expect(appStatus.state).To(Equal("started"))
+		It("accepts --map-route with --unmap-old", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--map-route", "node-prod.cfapps.io", "--unmap-old"}
+			Ω(parseError(scaleoverCmdPlugin.parseArgs(args))).Should(BeNil())
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs(args))
+			Ω(opts.MapRoute).Should(Equal("node-prod.cfapps.io"))
+			Ω(opts.UnmapOld).Should(BeTrue())
 		})
 
+		It("rejects --unmap-old without --map-route", func() {
+			Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--unmap-old"}))).ShouldNot(BeNil())
+		})
 	})
 
-	Describe("scale down", func() {
-		var appStatus *AppStatus
-    var appStatus2 *AppStatus
-    var zerotime time.Duration
-
-		BeforeEach(func() {
-      appStatus = &AppStatus{
-				name:           "foo",
-				countRequested: 1,
-				countRunning:   1,
-				state:          "started",
-			}
+	Describe("Rollback", func() {
+		It("should be enabled by --rollback-on-failure and imply --wait-for-start", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--rollback-on-failure"}))
+			Ω(opts.RollbackOnFailure).To(BeTrue())
+			Ω(opts.WaitForStarted).To(BeTrue())
+			Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--rollback-on-failure"}))).To(BeNil())
+		})
 
-      appStatus2 = &AppStatus{
-				name:           "bar",
-				countRequested: 3,
-				countRunning:   3,
-				state:          "started",
-			}
-			fakeCliConnection = &pluginfakes.FakeCliConnection{}
+		It("takes a start timeout and what to do about each start failure", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--start-timeout", "2m", "--on-start-failure", "rollback,timeout=continue"}
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs(args))
+			Ω(opts.WaitForStarted).To(BeTrue())
+			Ω(opts.StartTimeout).To(Equal(2 * time.Minute))
+			Ω(opts.OnStartFailure).To(Equal(scaleover.StartFailurePolicy{
+				Timeout: scaleover.StartContinue, Crash: scaleover.StartRollback, Error: scaleover.StartRollback}))
 
+			Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--start-timeout", "0s"}))).ShouldNot(BeNil())
+			Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--on-start-failure", "retry"}))).ShouldNot(BeNil())
 		})
 
-		It("Stops a started app going to zero instances", func() {
-			appStatus.scaleDown(fakeCliConnection, 0, 1, appStatus, false, zerotime)
-			Expect(appStatus.state).To(Equal("stopped"))
-		})
+		It("takes --balance ready and waits for the new instances", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m"}))
+			Ω(opts.Balance).To(Equal(scaleover.BalancePlan))
 
-    It("It decrements the amount requested", func() {
-			running := appStatus2.countRunning
-			appStatus2.scaleDown(fakeCliConnection, 0, 1, appStatus, false, zerotime)
-			Expect(appStatus2.countRequested).To(Equal(running - 1))
-      Expect(appStatus2.state).To(Equal("started"))
-		})
+			opts = parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--balance", "ready"}))
+			Ω(opts.Balance).To(Equal(scaleover.BalanceReady))
+			Ω(opts.WaitForStarted).To(BeTrue())
 
-    It("It decrements the batch-size 1 but leaves 1 stopped", func() {
-			appStatus.scaleDown(fakeCliConnection, 0, 1, appStatus, false, zerotime)
-			Expect(appStatus.countRequested).To(Equal(1)) //
-      Expect(appStatus.state).To(Equal("stopped"))
+			Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--balance", "eager"}))).ShouldNot(BeNil())
 		})
 
-    It("It decrements the batch-size requested but leaves 1 stopped", func() {
-			running := appStatus2.countRunning
-      batchSize := 2
-			appStatus2.scaleDown(fakeCliConnection, 0, batchSize, appStatus, false, zerotime)
-			Expect(appStatus2.countRequested).To(Equal(running - batchSize)) //
-      Expect(appStatus2.state).To(Equal("started"))
-		})
+		It("takes --min-available and --max-surge as counts or percentages", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--min-available", "75%", "--max-surge=1"}
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs(args))
+			Ω(*opts.MinAvailable).To(Equal(scaleover.Amount{N: 75, Percent: true}))
+			Ω(*opts.MaxSurge).To(Equal(scaleover.Amount{N: 1}))
+			Ω(opts.WaitForStarted).To(BeTrue())
+
+			opts = parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m"}))
+			Ω(opts.MinAvailable).To(BeNil())
+			Ω(opts.MaxSurge).To(BeNil())
 
-    It("Leaves 1 instance stopped", func() {
-      appStatus.countRequested = 1
-      appStatus.countRunning = 1
-			appStatus.scaleDown(fakeCliConnection, 0, 1, appStatus, false, zerotime)
-			Expect(appStatus.countRequested).To(Equal(1))
-      Expect(appStatus.state).To(Equal("stopped"))
+			err := parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--max-surge", "some"}))
+			Ω(err.Error()).To(HavePrefix(`--max-surge "some" must be a whole number of instances or a percentage like 25%`))
 		})
+	})
 
-		It("Leaves a stopped app stopped", func() {
-			appStatus.state = "stopped"
-			appStatus.scaleDown(fakeCliConnection, 0, 1, appStatus, false, zerotime)
-			Expect(appStatus.state).To(Equal("stopped"))
+	Describe("Health check", func() {
+		It("reads its settings from the args", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--health-url", "http://green.example.com/health",
+				"--health-status", "204", "--health-successes", "3", "--health-timeout", "30s", "--health-body-regex", "UP"}
+			Expect(parseError(scaleoverCmdPlugin.parseArgs(args))).To(BeNil())
+
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs(args))
+			Expect(opts.HealthCheck.URL).To(Equal("http://green.example.com/health"))
+			Expect(opts.HealthCheck.Status).To(Equal(204))
+			Expect(opts.HealthCheck.Successes).To(Equal(3))
+			Expect(opts.HealthCheck.Timeout).To(Equal(30 * time.Second))
+			Expect(opts.HealthCheck.BodyRegex).To(Equal("UP"))
 		})
 
-		It("Scales down the app", func() {
-			appStatus.countRequested = 2
-			appStatus.scaleDown(fakeCliConnection, 0, 1, appStatus, false, zerotime)
-			Expect(appStatus.countRunning).To(Equal(1))
-			Expect(fakeCliConnection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(1))
+		It("rejects a body pattern that doesn't compile", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--health-body-regex", "("}))).NotTo(BeNil())
 		})
 	})
 
-	Describe("Usage", func() {
-		BeforeEach(func() {
-			scaleoverCmdPlugin = &ScaleoverCmd{}
+	Describe("--on-interrupt", func() {
+		It("defaults to hold", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m"}))
+			Expect(opts.OnInterrupt).To(Equal(scaleover.InterruptHold))
 		})
 
-		It("shows usage for too few arguments", func() {
-			Expect(scaleoverCmdPlugin.usage([]string{"scaleover"})).NotTo(BeNil())
+		It("accepts rollback with or without an =", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--on-interrupt=rollback"}))
+			Expect(opts.OnInterrupt).To(Equal(scaleover.InterruptRollback))
+			opts = parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--on-interrupt", "rollback"}))
+			Expect(opts.OnInterrupt).To(Equal(scaleover.InterruptRollback))
 		})
 
-		It("shows usage for too many arguments", func() {
-			Expect(scaleoverCmdPlugin.usage([]string{"scaleover", "two", "three", "1m", "foo"})).NotTo(BeNil())
+		It("rejects policies that need someone to ask", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--on-interrupt=abort"}))).NotTo(BeNil())
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--on-interrupt=hold"}))).To(BeNil())
 		})
 
-		It("is just right", func() {
-			Expect(scaleoverCmdPlugin.usage([]string{"scaleover", "two", "three", "1m"})).To(BeNil())
-		})
+		Describe("prompting on a terminal", func() {
+			prompt := func(input string) string {
+				return promptInterruptAction(bufio.NewReader(strings.NewReader(input)), ioutil.Discard)
+			}
 
-		It("is okay with --no-route-check at the end", func() {
-			Expect(scaleoverCmdPlugin.usage([]string{"scaleover", "two", "three", "1m", "--no-route-check"})).To(BeNil())
-		})
+			It("understands each choice", func() {
+				Expect(prompt("c\n")).To(Equal(scaleover.InterruptContinue))
+				Expect(prompt("hold\n")).To(Equal(scaleover.InterruptHold))
+				Expect(prompt("A\n")).To(Equal(scaleover.InterruptAbort))
+				Expect(prompt("r\n")).To(Equal(scaleover.InterruptRollback))
+			})
 
-		It("is okay with --leave n at the end", func() {
-			Expect(scaleoverCmdPlugin.usage([]string{"scaleover", "two", "three", "1m", "--leave", "1"})).To(BeNil())
-		})
+			It("asks again until it gets an answer it understands", func() {
+				out := &bytes.Buffer{}
+				action := promptInterruptAction(bufio.NewReader(strings.NewReader("what\nr\n")), out)
+				Expect(action).To(Equal(scaleover.InterruptRollback))
+				Expect(strings.Count(out.String(), "[r]oll back")).To(Equal(2))
+			})
 
-		It("is okay with --no-route-check and --leave n at the end", func() {
-			Expect(scaleoverCmdPlugin.usage([]string{"scaleover", "two", "three", "1m", "--no-route-check", "--leave", "1"})).To(BeNil())
+			It("holds when stdin goes away", func() {
+				Expect(prompt("")).To(Equal(scaleover.InterruptHold))
+			})
 		})
+	})
 
-		It("is N should fail if not a number", func() {
-			Expect(scaleoverCmdPlugin.usage([]string{"scaleover", "two", "three", "1m", "--leave", "a"})).NotTo(BeNil())
+	Describe("Dry run", func() {
+		It("is turned on by --dry-run", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--dry-run"}
+			Expect(parseError(scaleoverCmdPlugin.parseArgs(args))).To(BeNil())
+			Expect(parsedOptions(scaleoverCmdPlugin.parseArgs(args)).DryRun).To(BeTrue())
 		})
+	})
 
-		It("is gives usage with --no-route-check in an unusual position", func() {
-			Expect(scaleoverCmdPlugin.usage([]string{"scaleover", "two", "--no-route-check", "three", "1m"})).ToNot(BeNil())
+	Describe("Quota check", func() {
+		It("is on unless --no-quota-check is given", func() {
+			Ω(parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m"})).QuotaCheck).Should(BeTrue())
+			args := []string{"scaleover", "two", "three", "1m", "--no-quota-check"}
+			Ω(parseError(scaleoverCmdPlugin.parseArgs(args))).Should(BeNil())
+			Ω(parsedOptions(scaleoverCmdPlugin.parseArgs(args)).QuotaCheck).Should(BeFalse())
 		})
 	})
 
-	Describe("Routes", func() {
-		BeforeEach(func() {
-			scaleoverCmdPlugin = &ScaleoverCmd{}
-			var app1 = &AppStatus{
-				routes: []string{"a.b.c", "b.c.d"},
-			}
-			var app2 = &AppStatus{
-				routes: []string{"c.d.e", "d.e.f"},
-			}
-			scaleoverCmdPlugin.app1 = app1
-			scaleoverCmdPlugin.app2 = app2
+	Describe("Retries", func() {
+		It("are retried per --retries and --retry-backoff", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--retries", "3", "--retry-backoff", "10s"}
+			Ω(parseError(scaleoverCmdPlugin.parseArgs(args))).Should(BeNil())
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs(args))
+			Ω(opts.Retry).Should(Equal(scaleover.RetryPolicy{Retries: 3, Backoff: 10 * time.Second}))
 		})
 
-		It("should return false if the apps don't share a route", func() {
-			Expect(scaleoverCmdPlugin.appsShareARoute()).To(BeFalse())
+		It("are not retried by default", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m"}))
+			Ω(opts.Retry.Retries).Should(Equal(0))
+			Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--retries", "-1"}))).ShouldNot(BeNil())
 		})
+	})
 
-		It("should return true when they share a route", func() {
-			scaleoverCmdPlugin.app2 = scaleoverCmdPlugin.app1
-			Expect(scaleoverCmdPlugin.appsShareARoute()).To(BeTrue())
-		})
+	Describe("State file", func() {
+		stateFile := filepath.Join(os.TempDir(), "scaleover-state.json")
 
-		It("Should warn when apps don't share a route", func() {
-			Expect(scaleoverCmdPlugin.errorIfNoSharedRoute().Error()).To(Equal("Apps do not share a route!"))
+		It("should take the state file from the args", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--state-file", stateFile}))
+			Expect(opts.StateFile).To(Equal(stateFile))
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--state-file", stateFile}))).To(BeNil())
 		})
+	})
 
-		It("Should be just fine if apps share a route", func() {
-			scaleoverCmdPlugin.app2.routes = append(scaleoverCmdPlugin.app2.routes, "a.b.c")
-			Expect(scaleoverCmdPlugin.errorIfNoSharedRoute()).To(BeNil())
-		})
+	Describe("Events", func() {
+		logFile := filepath.Join(os.TempDir(), "scaleover.log")
 
-		It("Should ignore route sanity if --no-route-check is at the end of args", func() {
-      enforceRoutes, _, _, _, _ := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--no-route-check"})
-      //enforceRoutes, leave, waitForStarted, postStartSleep, batchSize := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--no-route-check"})
-			Expect(enforceRoutes).To(BeFalse())
+		It("accepts --output json and --log-file", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--output", "json", "--log-file", logFile}
+			Ω(parseError(scaleoverCmdPlugin.parseArgs(args))).Should(BeNil())
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs(args))
+			Ω(opts.Output).Should(Equal(scaleover.OutputJSON))
+			Ω(opts.LogFile).Should(Equal(logFile))
 		})
 
-		It("Should carfuly consider routes if --no-route-check is not in the args", func() {
-			enforceRoutes, _, _, _, _ := scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m"})
-			Expect(enforceRoutes).To(BeTrue())
+		It("rejects other outputs", func() {
+			Ω(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--output", "yaml"}))).ShouldNot(BeNil())
 		})
 	})
 
-	Describe("Do Scaleover", func() {
-    var zerotime time.Duration
-		BeforeEach(func() {
-			scaleoverCmdPlugin = &ScaleoverCmd{}
-			var app1 = &AppStatus{
-				countRunning:   10,
-				countRequested: 10,
-        state:          "started",
-			}
-			var app2 = &AppStatus{
-				countRunning:   0,
-				countRequested: 0,
-        state:          "stopped",
-			}
-			scaleoverCmdPlugin.app1 = app1
-			scaleoverCmdPlugin.app2 = app2
+	Describe("Strategy", func() {
+		It("defaults to linear", func() {
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m"}))
+			Expect(opts.Strategy).To(Equal(scaleover.StrategyLinear))
+		})
+
+		It("takes the strategy from the args", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--strategy", "exponential"}
+			Expect(parseError(scaleoverCmdPlugin.parseArgs(args))).To(BeNil())
+			Expect(parsedOptions(scaleoverCmdPlugin.parseArgs(args)).Strategy).To(Equal(scaleover.StrategyExponential))
 		})
 
-		It("should scale app2 to 10", func() {
-			scaleoverCmdPlugin.doScaleover(fakeCliConnection, 10, 0, 0, false, zerotime, 1)
-			Ω(scaleoverCmdPlugin.app2.countRequested).To(Equal(10))
-			Ω(scaleoverCmdPlugin.app1.countRequested).To(Equal(1))
-      Ω(scaleoverCmdPlugin.app1.state).To(Equal("stopped"))
+		It("uses the steps strategy when given --steps", func() {
+			args := []string{"scaleover", "two", "three", "1m", "--steps", "10%,100%"}
+			Expect(parseError(scaleoverCmdPlugin.parseArgs(args))).To(BeNil())
+			opts := parsedOptions(scaleoverCmdPlugin.parseArgs(args))
+			Expect(opts.Strategy).To(Equal(scaleover.StrategySteps))
+			Expect(opts.Steps).To(Equal([]int{10, 100}))
 		})
 
-		It("should scale app2 to 10 and app1 down to N if a <leave N> is specified", func() {
-			scaleoverCmdPlugin.doScaleover(fakeCliConnection, 10, 1, 0, false, zerotime, 1)
-			Ω(scaleoverCmdPlugin.app2.countRequested).To(Equal(10))
-			Ω(scaleoverCmdPlugin.app1.countRequested).To(Equal(1))
-      Ω(scaleoverCmdPlugin.app1.state).To(Equal("started"))
+		It("rejects strategies it doesn't know", func() {
+			Expect(parseError(scaleoverCmdPlugin.parseArgs([]string{"scaleover", "two", "three", "1m", "--strategy", "random"}))).NotTo(BeNil())
 		})
 	})
 })